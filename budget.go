@@ -0,0 +1,242 @@
+package lru_cache
+
+import (
+	"sync"
+	"time"
+)
+
+// BudgetPolicy decides how a Budget splits its bytes between groups.
+type BudgetPolicy int
+
+const (
+	// BudgetFixed splits the budget by weight alone.
+	BudgetFixed BudgetPolicy = iota
+	// BudgetHitRate scales each weight by the group's hit rate since the last
+	// rebalance, so groups that turn memory into hits get more of it.
+	BudgetHitRate
+)
+
+// budget_floor keeps some share for groups without traffic under
+// BudgetHitRate, so they can warm up and earn a larger share.
+const budget_floor = 0.1
+
+// Budget is a process-level memory budget shared by the groups registered
+// with it. Each rebalance resizes every group's main cache to its share.
+type Budget struct {
+	mutex        sync.Mutex
+	max_bytes    int64
+	policy       BudgetPolicy
+	members      []*budget_member
+	stop_channel chan struct{}
+	done_channel chan struct{}
+}
+
+type budget_member struct {
+	group       *Group
+	weight      float64
+	last_hits   uint64
+	last_misses uint64
+	hit_rate    float64
+	allocated   int64
+	applying    bool // a goroutine is resizing the group to allocated
+}
+
+// BudgetUsage describes one group's share of a Budget.
+type BudgetUsage struct {
+	Group     string
+	Weight    float64
+	HitRate   float64 // hit rate measured at the last rebalance
+	Allocated int64   // cache bytes the group was given
+	Bytes     int64   // cache bytes the group currently uses
+	Entries   int
+}
+
+// NewBudget creates a budget of max_bytes split with policy. A max_bytes of
+// 0 or less leaves every group's cache unlimited.
+func NewBudget(max_bytes int64, policy BudgetPolicy) *Budget {
+	return &Budget{max_bytes: max_bytes, policy: policy}
+}
+
+// WithBudget puts the group's main cache under budget with weight, replacing
+// the cache_bytes passed to NewGroup. Weights below or equal to 0 count as 1.
+func WithBudget(budget *Budget, weight float64) GroupOption {
+	return func(group *Group) {
+		group.budget = budget
+		group.budget_weight = weight
+	}
+}
+
+// register adds group to the budget and rebalances. Groups join through
+// WithBudget, so Close always knows which budget to leave.
+func (budget *Budget) register(group *Group, weight float64) {
+	if weight <= 0 {
+		weight = 1
+	}
+	budget.mutex.Lock()
+	for _, member := range budget.members {
+		if member.group == group {
+			member.weight = weight
+			budget.rebalance_and_apply()
+			return
+		}
+	}
+	hits, misses := group.main_cache.Stats()
+	budget.members = append(budget.members, &budget_member{
+		group:       group,
+		weight:      weight,
+		last_hits:   hits,
+		last_misses: misses,
+	})
+	budget.rebalance_and_apply()
+}
+
+// unregister removes group from the budget and gives its share to the rest.
+// The group keeps its last allocation.
+func (budget *Budget) unregister(group *Group) {
+	budget.mutex.Lock()
+	for index, member := range budget.members {
+		if member.group == group {
+			budget.members = append(budget.members[:index], budget.members[index+1:]...)
+			budget.rebalance_and_apply()
+			return
+		}
+	}
+	budget.mutex.Unlock()
+}
+
+// SetMaxBytes changes the total budget and rebalances.
+func (budget *Budget) SetMaxBytes(max_bytes int64) {
+	budget.mutex.Lock()
+	budget.max_bytes = max_bytes
+	budget.rebalance_and_apply()
+}
+
+// Rebalance recomputes every group's share now.
+func (budget *Budget) Rebalance() {
+	budget.mutex.Lock()
+	budget.rebalance_and_apply()
+}
+
+// Start rebalances every interval until Stop is called. Calling it again
+// restarts the loop with the new interval.
+func (budget *Budget) Start(interval time.Duration) {
+	budget.Stop()
+	stop_channel := make(chan struct{})
+	done_channel := make(chan struct{})
+	budget.mutex.Lock()
+	budget.stop_channel, budget.done_channel = stop_channel, done_channel
+	budget.mutex.Unlock()
+	go func() {
+		defer close(done_channel)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop_channel:
+				return
+			case <-ticker.C:
+				budget.Rebalance()
+			}
+		}
+	}()
+}
+
+// Stop stops the rebalance loop and waits for it to exit.
+func (budget *Budget) Stop() {
+	budget.mutex.Lock()
+	stop_channel, done_channel := budget.stop_channel, budget.done_channel
+	budget.stop_channel, budget.done_channel = nil, nil
+	budget.mutex.Unlock()
+	if stop_channel != nil {
+		close(stop_channel)
+		<-done_channel
+	}
+}
+
+// Usage returns each registered group's share and current usage.
+func (budget *Budget) Usage() []BudgetUsage {
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	usage := make([]BudgetUsage, 0, len(budget.members))
+	for _, member := range budget.members {
+		usage = append(usage, BudgetUsage{
+			Group:     member.group.group_name,
+			Weight:    member.weight,
+			HitRate:   member.hit_rate,
+			Allocated: member.allocated,
+			Bytes:     member.group.main_cache.Bytes(),
+			Entries:   member.group.main_cache.Len(),
+		})
+	}
+	return usage
+}
+
+// rebalance_and_apply is called with the budget lock held and releases it.
+// Caches are resized after the lock is dropped, so eviction listeners may
+// call back into the budget.
+func (budget *Budget) rebalance_and_apply() {
+	members := budget.rebalance()
+	budget.mutex.Unlock()
+	for _, member := range members {
+		budget.apply(member)
+	}
+}
+
+// rebalance is called with the budget lock held and returns the members to
+// resize. Shares are rounded down, so the allocations never add
+// up to more than max_bytes.
+func (budget *Budget) rebalance() []*budget_member {
+	var total_score float64
+	scores := make([]float64, len(budget.members))
+	for index, member := range budget.members {
+		hits, misses := member.group.main_cache.Stats()
+		if lookups := (hits - member.last_hits) + (misses - member.last_misses); lookups > 0 {
+			member.hit_rate = float64(hits-member.last_hits) / float64(lookups)
+		}
+		member.last_hits, member.last_misses = hits, misses
+
+		scores[index] = member.weight
+		if budget.policy == BudgetHitRate {
+			scores[index] *= budget_floor + member.hit_rate
+		}
+		total_score += scores[index]
+	}
+	for index, member := range budget.members {
+		member.allocated = 0
+		if budget.max_bytes > 0 {
+			share := 1 / float64(len(budget.members))
+			if total_score > 0 {
+				share = scores[index] / total_score
+			}
+			// 0 would mean unlimited, so a share that rounds down to nothing
+			// still gets a byte.
+			member.allocated = max(int64(float64(budget.max_bytes)*share), 1)
+		}
+	}
+	return append([]*budget_member(nil), budget.members...)
+}
+
+// apply resizes member's cache to its latest allocation. Only one goroutine
+// resizes a member at a time; it repeats until the allocation it applied is
+// still current, so concurrent rebalances never leave an older size behind.
+func (budget *Budget) apply(member *budget_member) {
+	budget.mutex.Lock()
+	if member.applying {
+		budget.mutex.Unlock()
+		return
+	}
+	member.applying = true
+	for {
+		allocated := member.allocated
+		budget.mutex.Unlock()
+		// Every built-in store can be resized; a cache that cannot simply
+		// keeps its size.
+		_ = member.group.SetCacheBytes(allocated)
+		budget.mutex.Lock()
+		if member.allocated == allocated {
+			member.applying = false
+			budget.mutex.Unlock()
+			return
+		}
+	}
+}
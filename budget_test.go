@@ -0,0 +1,125 @@
+package lru_cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func budget_test_getter() Getter {
+	return GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value"), nil
+	})
+}
+
+func TestBudgetFixedSplitsByWeight(t *testing.T) {
+	budget := NewBudget(4000, BudgetFixed)
+	small := NewGroup("budget_small", 1<<20, budget_test_getter(), WithBudget(budget, 1))
+	large := NewGroup("budget_large", 1<<20, budget_test_getter(), WithBudget(budget, 3))
+	defer small.Close()
+	defer large.Close()
+
+	for index := 0; index < 1000; index++ {
+		key := "k" + strconv.Itoa(index)
+		small.Get(key)
+		large.Get(key)
+	}
+	usage := budget.Usage()
+	if len(usage) != 2 || usage[0].Allocated != 1000 || usage[1].Allocated != 3000 {
+		t.Fatalf("expected 1000/3000 allocations, got %+v", usage)
+	}
+	if usage[0].Bytes > 1000 || usage[1].Bytes > 3000 || usage[0].Bytes+usage[1].Bytes > 4000 {
+		t.Fatalf("expected groups within their shares, got %+v", usage)
+	}
+
+	small.Close()
+	if usage = budget.Usage(); len(usage) != 1 || usage[0].Allocated != 4000 {
+		t.Fatalf("expected the remaining group to get the whole budget, got %+v", usage)
+	}
+}
+
+func TestBudgetDestroyGroupLeavesBudget(t *testing.T) {
+	budget := NewBudget(4000, BudgetFixed)
+	registry := NewGroupRegistry(DuplicateReplace)
+	kept := NewGroup("budget_kept", 1<<20, budget_test_getter(), WithBudget(budget, 1), WithRegistry(registry))
+	NewGroup("budget_destroyed", 1<<20, budget_test_getter(), WithBudget(budget, 1), WithRegistry(registry))
+	defer kept.Close()
+
+	if !registry.Destroy("budget_destroyed") {
+		t.Fatalf("expected the group to be destroyed")
+	}
+	if usage := budget.Usage(); len(usage) != 1 || usage[0].Group != "budget_kept" || usage[0].Allocated != 4000 {
+		t.Fatalf("expected the destroyed group to leave the budget, got %+v", usage)
+	}
+}
+
+func TestBudgetHitRateFavoursUsefulGroups(t *testing.T) {
+	budget := NewBudget(10000, BudgetHitRate)
+	hot := NewGroup("budget_hot", 0, budget_test_getter(), WithBudget(budget, 1))
+	scan := NewGroup("budget_scan", 0, budget_test_getter(), WithBudget(budget, 1))
+	defer hot.Close()
+	defer scan.Close()
+
+	for round := 0; round < 10; round++ {
+		for index := 0; index < 20; index++ {
+			hot.Get("k" + strconv.Itoa(index))
+		}
+	}
+	for index := 0; index < 200; index++ {
+		scan.Get("scan" + strconv.Itoa(index+1000))
+	}
+	budget.Rebalance()
+
+	usage := budget.Usage()
+	if usage[0].HitRate < 0.8 || usage[1].HitRate != 0 {
+		t.Fatalf("expected hit rates near 0.9 and 0, got %+v", usage)
+	}
+	if usage[0].Allocated <= 5*usage[1].Allocated {
+		t.Fatalf("expected the hot group to get most of the budget, got %+v", usage)
+	}
+	if usage[0].Allocated+usage[1].Allocated > 10000 {
+		t.Fatalf("expected allocations within the budget, got %+v", usage)
+	}
+}
+
+func TestBudgetZeroIsUnlimited(t *testing.T) {
+	budget := NewBudget(0, BudgetFixed)
+	group := NewGroup("test_group_budget_unlimited", 10, budget_test_getter(), WithBudget(budget, 1))
+	defer group.Close()
+
+	for index := 0; index < 100; index++ {
+		group.Get("k" + strconv.Itoa(index))
+	}
+	if usage := budget.Usage(); len(usage) != 1 || usage[0].Allocated != 0 || usage[0].Entries != 100 {
+		t.Fatalf("expected an unlimited cache, got %+v", usage)
+	}
+}
+
+func TestBudgetListenerMayRebalance(t *testing.T) {
+	budget := NewBudget(1<<20, BudgetFixed)
+	group := NewGroup("test_group_budget_listener", 0, budget_test_getter(),
+		WithBudget(budget, 1),
+		OnEvict(func(key string, value ByteView, reason EvictReason) {
+			budget.Usage()
+			budget.Rebalance()
+		}),
+	)
+	defer group.Close()
+	for index := 0; index < 100; index++ {
+		group.Get("k" + strconv.Itoa(index))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		budget.SetMaxBytes(100)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected resizing outside the budget lock")
+	}
+	if usage := budget.Usage(); usage[0].Allocated != 100 || usage[0].Bytes > 100 {
+		t.Fatalf("expected the group shrunk to 100 bytes, got %+v", usage)
+	}
+}
@@ -4,28 +4,125 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unsafe"
 
 	"lru_cache/store"
 )
 
 // CacheOptions configures the local cache store.
 type CacheOptions struct {
-	Store_type string // "lru" or "lru2"
-	Max_bytes  int64
+	Store_type  string // "lru", "lru2", "tinylfu" or "arc"
+	Max_bytes   int64
+	Shard_count int           // 0 or 1 keeps a single lock
+	Stale_grace time.Duration // how long expired values stay servable as stale
+	Clock       Clock         // nil uses the system clock
+	On_evict    func(key string, value ByteView, reason EvictReason)
+	// Account_overhead charges each entry's estimated bookkeeping bytes
+	// against Max_bytes, not just its key and value.
+	Account_overhead bool
 }
 
-// Cache holds a local in-memory cache.
+// MemoryUsage estimates the memory held by a cache.
+type MemoryUsage struct {
+	Entries       int
+	PayloadBytes  int64 // key and value bytes
+	OverheadBytes int64 // list elements, map slots, entry wrappers and ARC ghosts
+	TotalBytes    int64
+}
+
+// EvictReason tells an eviction listener why a value left the cache.
+type EvictReason int
+
+const (
+	// EvictCapacity means the store dropped the value to stay within Max_bytes.
+	EvictCapacity EvictReason = iota
+	// EvictExpired means the value's ttl (and any stale grace) passed.
+	EvictExpired
+	// EvictRemoved means the value was deleted explicitly.
+	EvictRemoved
+	// EvictReplaced means a new value was stored under the same key.
+	EvictReplaced
+)
+
+func (reason EvictReason) String() string {
+	switch reason {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictRemoved:
+		return "removed"
+	case EvictReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// Clock supplies the current time, so tests can control expiry.
+type Clock interface {
+	Now() time.Time
+}
+
+type system_clock struct{}
+
+func (system_clock) Now() time.Time {
+	return time.Now()
+}
+
+// Cache holds a local in-memory cache. Keys are spread over independent
+// shards, each with its own lock and an equal share of Max_bytes.
 type Cache struct {
-	mutex      sync.Mutex
-	store      store.Store
-	options    CacheOptions
-	hit_count  uint64
-	miss_count uint64
+	shards        []*cache_shard
+	options       CacheOptions
+	sweeper_mutex sync.Mutex
+	sweeper       *expiry_sweeper
+	overhead      int64 // estimated bytes per entry beyond key and value
+	charged       bool  // whether the stores count overhead in Bytes
+}
+
+type cache_shard struct {
+	mutex          sync.Mutex
+	store          store.Store
+	max_bytes      int64            // this shard's share of Max_bytes
+	expiring       map[string]int64 // time after which each ttl key may be dropped
+	removing       bool             // set while the cache itself removes a key
+	remove_reason  EvictReason
+	on_evict       func(key string, value ByteView, reason EvictReason)
+	pending        []eviction_event // events to deliver once the lock is released
+	hit_count      uint64
+	miss_count     uint64
+	negative_count uint64
+	evicted_count  uint64
+	expired_count  uint64
+}
+
+type eviction_event struct {
+	key    string
+	value  ByteView
+	reason EvictReason
 }
 
 type cache_value struct {
 	value     ByteView
+	negative  error // set for tombstones, which replay an error instead of a value
+	stored_at int64
 	expire_at int64
+	version   uint64 // unique per stored value, see next_version
+}
+
+// next_version numbers stored values across every cache, so a version names
+// one value even when it moves between a group's main and hot caches.
+var next_version atomic.Uint64
+
+// entry_state describes the freshness of a cached value at lookup time.
+type entry_state struct {
+	ttl      time.Duration // time left before expiry, 0 when it never expires
+	lifetime time.Duration // full ttl the value was stored with
+	stale    bool          // expired but still inside the grace window
+	negative error         // error recorded by a tombstone
+	hot      bool          // found in the group's hot cache of peer-owned values
+	version  uint64        // version of the stored value
 }
 
 func (value *cache_value) Len() int {
@@ -38,76 +135,358 @@ func (value *cache_value) expired(current_time int64) bool {
 
 // NewCache creates a cache with options.
 func NewCache(options CacheOptions) *Cache {
-	return &Cache{
-		store:   new_store(options),
+	shard_count := options.Shard_count
+	if shard_count < 1 {
+		shard_count = 1
+	}
+	shard_options := options
+	if options.Max_bytes > 0 {
+		shard_options.Max_bytes = max(options.Max_bytes/int64(shard_count), 1)
+	}
+	if options.Clock == nil {
+		options.Clock = system_clock{}
+	}
+	cache := &Cache{
+		shards:  make([]*cache_shard, shard_count),
 		options: options,
 	}
+	for index := range cache.shards {
+		shard := &cache_shard{max_bytes: shard_options.Max_bytes, expiring: make(map[string]int64), on_evict: options.On_evict}
+		shard.store = new_store(shard_options, shard.on_evicted)
+		cache.shards[index] = shard
+	}
+	if accountant, ok := cache.shards[0].store.(store.Accountant); ok {
+		cache.overhead = accountant.EntryOverhead() + store.AllocationSize(unsafe.Sizeof(cache_value{}))
+		if options.Account_overhead {
+			for _, shard := range cache.shards {
+				shard.store.(store.Accountant).SetEntryOverhead(cache.overhead)
+			}
+			cache.charged = true
+		}
+	}
+	return cache
 }
 
-func new_store(options CacheOptions) store.Store {
+func new_store(options CacheOptions, on_evicted func(string, store.Value)) store.Store {
 	switch options.Store_type {
 	case "lru2":
-		return store.NewLRU2(options.Max_bytes, nil)
+		return store.NewLRU2(options.Max_bytes, on_evicted)
+	case "tinylfu":
+		return store.NewTinyLFU(options.Max_bytes, on_evicted)
+	case "arc":
+		return store.NewARC(options.Max_bytes, on_evicted)
 	default:
-		return store.NewLRU(options.Max_bytes, nil)
+		return store.NewLRU(options.Max_bytes, on_evicted)
+	}
+}
+
+// on_evicted is called by the store with the shard lock held. Removals the
+// cache asked for carry the caller's reason; anything else is a capacity
+// eviction.
+func (shard *cache_shard) on_evicted(key string, value store.Value) {
+	if shard.removing {
+		shard.record(key, value, shard.remove_reason)
+		return
+	}
+	atomic.AddUint64(&shard.evicted_count, 1)
+	delete(shard.expiring, key)
+	shard.record(key, value, EvictCapacity)
+}
+
+// record queues an eviction event for the listener. Tombstones are not
+// reported since they hold no value.
+func (shard *cache_shard) record(key string, value store.Value, reason EvictReason) {
+	stored_value, ok := value.(*cache_value)
+	if shard.on_evict == nil || !ok || stored_value.negative != nil {
+		return
+	}
+	shard.pending = append(shard.pending, eviction_event{key: key, value: stored_value.value, reason: reason})
+}
+
+// unlock releases the shard and then delivers queued eviction events, so the
+// listener may call back into the cache.
+func (shard *cache_shard) unlock() {
+	events := shard.pending
+	shard.pending = nil
+	shard.mutex.Unlock()
+	for _, event := range events {
+		shard.on_evict(event.key, event.value, event.reason)
+	}
+}
+
+func (shard *cache_shard) remove(key string, reason EvictReason) {
+	shard.removing = true
+	shard.remove_reason = reason
+	shard.store.Remove(key)
+	shard.removing = false
+	delete(shard.expiring, key)
+}
+
+// expire removes a key whose ttl has passed and reports whether it was still
+// stored.
+func (shard *cache_shard) expire(key string) bool {
+	entry_count := shard.store.Len()
+	shard.remove(key, EvictExpired)
+	if shard.store.Len() == entry_count {
+		return false
+	}
+	atomic.AddUint64(&shard.expired_count, 1)
+	return true
+}
+
+func (cache *Cache) shard(key string) *cache_shard {
+	if len(cache.shards) == 1 {
+		return cache.shards[0]
+	}
+	// Inline FNV-1a keeps shard selection allocation free.
+	hash_value := uint32(2166136261)
+	for index := 0; index < len(key); index++ {
+		hash_value ^= uint32(key[index])
+		hash_value *= 16777619
 	}
+	return cache.shards[hash_value%uint32(len(cache.shards))]
 }
 
 // Get returns a value from cache.
 func (cache *Cache) Get(key string) (ByteView, bool) {
-	cache.mutex.Lock()
-	defer cache.mutex.Unlock()
+	value, state, ok := cache.get_entry(key)
+	return value, ok && !state.stale && state.negative == nil
+}
+
+// get_entry returns a value with its freshness. Expired values are returned
+// as stale until Stale_grace has passed, then removed. Stale values count as
+// misses, as in Get, and tombstones are never served stale and count as
+// negative hits rather than hits.
+func (cache *Cache) get_entry(key string) (ByteView, entry_state, bool) {
+	value, state, ok := cache.peek_entry(key)
+	cache.record(key, state, ok)
+	return value, state, ok
+}
 
-	stored_value, ok := cache.store.Get(key)
+// peek_entry is get_entry without counting the lookup, for callers that
+// consult several caches and record one outcome with record.
+func (cache *Cache) peek_entry(key string) (ByteView, entry_state, bool) {
+	shard := cache.shard(key)
+	shard.mutex.Lock()
+	defer shard.unlock()
+
+	stored_value, ok := shard.store.Get(key)
 	if !ok {
-		atomic.AddUint64(&cache.miss_count, 1)
-		return ByteView{}, false
+		return ByteView{}, entry_state{}, false
 	}
 	cache_value := stored_value.(*cache_value)
-	if cache_value.expired(time.Now().UnixNano()) {
-		cache.store.Remove(key)
-		atomic.AddUint64(&cache.miss_count, 1)
-		return ByteView{}, false
+	current_time := cache.now()
+	var state entry_state
+	if cache_value.expire_at > 0 {
+		state.ttl = time.Duration(cache_value.expire_at - current_time)
+		state.lifetime = time.Duration(cache_value.expire_at - cache_value.stored_at)
+	}
+	state.version = cache_value.version
+	if cache_value.expired(current_time) {
+		if cache_value.negative != nil || current_time >= cache_value.expire_at+int64(cache.options.Stale_grace) {
+			shard.expire(key)
+			return ByteView{}, entry_state{}, false
+		}
+		state.ttl = 0
+		state.stale = true
+	}
+	if cache_value.negative != nil {
+		state.negative = cache_value.negative
+		return ByteView{}, state, true
+	}
+	return cache_value.value, state, true
+}
+
+// record counts the outcome of a lookup made with peek_entry.
+func (cache *Cache) record(key string, state entry_state, ok bool) {
+	shard := cache.shard(key)
+	switch {
+	case !ok || state.stale:
+		atomic.AddUint64(&shard.miss_count, 1)
+	case state.negative != nil:
+		atomic.AddUint64(&shard.negative_count, 1)
+	default:
+		atomic.AddUint64(&shard.hit_count, 1)
 	}
-	atomic.AddUint64(&cache.hit_count, 1)
-	return cache_value.value, true
 }
 
 // Set stores a value with optional ttl (0 means no expiration).
 func (cache *Cache) Set(key string, value ByteView, ttl time.Duration) {
-	var expire_at int64
+	cache.set_value(key, &cache_value{value: value}, ttl)
+}
+
+// set_versioned is Set returning the version given to the stored value.
+func (cache *Cache) set_versioned(key string, value ByteView, ttl time.Duration) uint64 {
+	stored_value := &cache_value{value: value}
+	cache.set_value(key, stored_value, ttl)
+	return stored_value.version
+}
+
+// set_negative stores a tombstone that makes lookups return error_value until
+// ttl passes. Tombstones hold no value bytes, only the key.
+func (cache *Cache) set_negative(key string, error_value error, ttl time.Duration) {
+	cache.set_value(key, &cache_value{negative: error_value}, ttl)
+}
+
+func (cache *Cache) set_value(key string, stored_value *cache_value, ttl time.Duration) {
+	current_time := cache.now()
+	stored_value.stored_at = current_time
+	stored_value.version = next_version.Add(1)
 	if ttl > 0 {
-		expire_at = time.Now().Add(ttl).UnixNano()
+		stored_value.expire_at = current_time + int64(ttl)
 	}
-	stored_value := &cache_value{value: value, expire_at: expire_at}
 
-	cache.mutex.Lock()
-	defer cache.mutex.Unlock()
-	cache.store.Add(key, stored_value)
+	shard := cache.shard(key)
+	shard.mutex.Lock()
+	defer shard.unlock()
+	if shard.on_evict != nil {
+		if peeker, ok := shard.store.(store.Peeker); ok {
+			if previous_value, ok := peeker.Peek(key); ok {
+				shard.record(key, previous_value, EvictReplaced)
+			}
+		}
+	}
+	if stored_value.expire_at > 0 {
+		deadline := stored_value.expire_at
+		if stored_value.negative == nil {
+			deadline += int64(cache.options.Stale_grace)
+		}
+		shard.expiring[key] = deadline
+	} else {
+		delete(shard.expiring, key)
+	}
+	shard.store.Add(key, stored_value)
 }
 
 // Remove deletes a key.
 func (cache *Cache) Remove(key string) {
-	cache.mutex.Lock()
-	defer cache.mutex.Unlock()
-	cache.store.Remove(key)
+	shard := cache.shard(key)
+	shard.mutex.Lock()
+	defer shard.unlock()
+	shard.remove(key, EvictRemoved)
+}
+
+// SetMaxBytes changes the capacity of a live cache, splitting it evenly over
+// the shards. Shrinking evicts with EvictCapacity in the store's policy order.
+// It returns ErrResizeUnsupported, changing nothing, when the store does not
+// implement store.Resizer.
+func (cache *Cache) SetMaxBytes(max_bytes int64) error {
+	for _, shard := range cache.shards {
+		if _, ok := shard.store.(store.Resizer); !ok {
+			return ErrResizeUnsupported
+		}
+	}
+	shard_bytes := max_bytes
+	if max_bytes > 0 {
+		shard_bytes = max(max_bytes/int64(len(cache.shards)), 1)
+	}
+	for _, shard := range cache.shards {
+		shard.mutex.Lock()
+		shard.max_bytes = shard_bytes
+		shard.store.(store.Resizer).SetMaxBytes(shard_bytes)
+		shard.unlock()
+	}
+	return nil
+}
+
+// Clear drops every entry without notifying the eviction listener. Counters
+// are kept.
+func (cache *Cache) Clear() {
+	for _, shard := range cache.shards {
+		shard.mutex.Lock()
+		options := cache.options
+		options.Max_bytes = shard.max_bytes
+		shard.store = new_store(options, shard.on_evicted)
+		if cache.charged {
+			shard.store.(store.Accountant).SetEntryOverhead(cache.overhead)
+		}
+		shard.expiring = make(map[string]int64)
+		shard.mutex.Unlock()
+	}
 }
 
 // Len returns the number of entries in the cache.
 func (cache *Cache) Len() int {
-	cache.mutex.Lock()
-	defer cache.mutex.Unlock()
-	return cache.store.Len()
+	total := 0
+	for _, shard := range cache.shards {
+		shard.mutex.Lock()
+		total += shard.store.Len()
+		shard.mutex.Unlock()
+	}
+	return total
 }
 
 // Bytes returns the total bytes tracked by the cache.
 func (cache *Cache) Bytes() int64 {
-	cache.mutex.Lock()
-	defer cache.mutex.Unlock()
-	return cache.store.Bytes()
+	var total int64
+	for _, shard := range cache.shards {
+		shard.mutex.Lock()
+		total += shard.store.Bytes()
+		shard.mutex.Unlock()
+	}
+	return total
+}
+
+// MemoryUsage reports the payload bytes and the estimated overhead of the
+// stored entries, whether or not Account_overhead is set. The overhead also
+// covers the ttl bookkeeping and ARC ghost entries, which are never charged
+// against Max_bytes.
+func (cache *Cache) MemoryUsage() MemoryUsage {
+	var usage MemoryUsage
+	var stored_bytes, untracked_bytes int64
+	for _, shard := range cache.shards {
+		shard.mutex.Lock()
+		usage.Entries += shard.store.Len()
+		stored_bytes += shard.store.Bytes()
+		untracked_bytes += int64(len(shard.expiring)) * store.MapEntryOverhead
+		if ghost_keeper, ok := shard.store.(store.GhostKeeper); ok {
+			untracked_bytes += ghost_keeper.GhostBytes()
+		}
+		shard.mutex.Unlock()
+	}
+	usage.OverheadBytes = int64(usage.Entries) * cache.overhead
+	usage.PayloadBytes = stored_bytes
+	if cache.charged {
+		usage.PayloadBytes -= usage.OverheadBytes
+	}
+	usage.OverheadBytes += untracked_bytes
+	usage.TotalBytes = usage.PayloadBytes + usage.OverheadBytes
+	return usage
 }
 
 // Stats returns cache hit/miss stats.
 func (cache *Cache) Stats() (hits uint64, misses uint64) {
-	return atomic.LoadUint64(&cache.hit_count), atomic.LoadUint64(&cache.miss_count)
+	for _, shard := range cache.shards {
+		hits += atomic.LoadUint64(&shard.hit_count)
+		misses += atomic.LoadUint64(&shard.miss_count)
+	}
+	return hits, misses
+}
+
+// NegativeHits returns how many lookups were answered by a tombstone.
+func (cache *Cache) NegativeHits() uint64 {
+	var total uint64
+	for _, shard := range cache.shards {
+		total += atomic.LoadUint64(&shard.negative_count)
+	}
+	return total
+}
+
+// EvictionStats returns how many entries were evicted for capacity and how
+// many were dropped because their ttl passed.
+func (cache *Cache) EvictionStats() (evicted uint64, expired uint64) {
+	for _, shard := range cache.shards {
+		evicted += atomic.LoadUint64(&shard.evicted_count)
+		expired += atomic.LoadUint64(&shard.expired_count)
+	}
+	return evicted, expired
+}
+
+// ShardCount returns the number of independent shards.
+func (cache *Cache) ShardCount() int {
+	return len(cache.shards)
+}
+
+func (cache *Cache) now() int64 {
+	return cache.options.Clock.Now().UnixNano()
 }
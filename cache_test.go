@@ -0,0 +1,200 @@
+package lru_cache
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"lru_cache/store"
+)
+
+func TestShardedCacheAggregates(t *testing.T) {
+	cache := NewCache(CacheOptions{Max_bytes: 1 << 20, Shard_count: 8})
+	for index := 0; index < 100; index++ {
+		cache.Set("k"+strconv.Itoa(index), ByteView{bytes: []byte("v")}, 0)
+	}
+	for index := 0; index < 100; index++ {
+		if _, ok := cache.Get("k" + strconv.Itoa(index)); !ok {
+			t.Fatalf("expected k%d to be cached", index)
+		}
+	}
+	cache.Get("missing")
+
+	if cache.Len() != 100 {
+		t.Fatalf("expected 100 entries, got %d", cache.Len())
+	}
+	if cache.Bytes() != 10*3+90*4 {
+		t.Fatalf("unexpected bytes: %d", cache.Bytes())
+	}
+	if hits, misses := cache.Stats(); hits != 100 || misses != 1 {
+		t.Fatalf("unexpected stats: hits=%d misses=%d", hits, misses)
+	}
+}
+
+func benchmark_cache_get(b *testing.B, shard_count int) {
+	cache := NewCache(CacheOptions{Max_bytes: 64 << 20, Shard_count: shard_count})
+	keys := make([]string, 4096)
+	for index := range keys {
+		keys[index] = "key" + strconv.Itoa(index)
+		cache.Set(keys[index], ByteView{bytes: []byte("value")}, 0)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		index := 0
+		for pb.Next() {
+			cache.Get(keys[index%len(keys)])
+			index++
+		}
+	})
+}
+
+func benchmark_cache_mixed(b *testing.B, shard_count int) {
+	cache := NewCache(CacheOptions{Max_bytes: 64 << 20, Shard_count: shard_count})
+	keys := make([]string, 4096)
+	for index := range keys {
+		keys[index] = "key" + strconv.Itoa(index)
+	}
+	value := ByteView{bytes: []byte("value")}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		index := 0
+		for pb.Next() {
+			key := keys[index%len(keys)]
+			if index%4 == 0 {
+				cache.Set(key, value, 0)
+			} else {
+				cache.Get(key)
+			}
+			index++
+		}
+	})
+}
+
+func BenchmarkCacheGetSingleLock(b *testing.B)   { benchmark_cache_get(b, 1) }
+func BenchmarkCacheGetSharded(b *testing.B)      { benchmark_cache_get(b, 32) }
+func BenchmarkCacheMixedSingleLock(b *testing.B) { benchmark_cache_mixed(b, 1) }
+func BenchmarkCacheMixedSharded(b *testing.B)    { benchmark_cache_mixed(b, 32) }
+
+func TestCacheEvictionListenerReasons(t *testing.T) {
+	clock := &test_clock{current_time: time.Unix(1000, 0)}
+	var cache *Cache
+	reasons := make(map[string][]EvictReason)
+	cache = NewCache(CacheOptions{
+		Max_bytes: 20,
+		Clock:     clock,
+		On_evict: func(key string, value ByteView, reason EvictReason) {
+			cache.Len() // would deadlock if called under the shard lock
+			reasons[key+"="+value.String()] = append(reasons[key+"="+value.String()], reason)
+		},
+	})
+
+	cache.Set("k1", ByteView{bytes: []byte("old")}, 0)
+	cache.Set("k1", ByteView{bytes: []byte("new")}, 0)
+	cache.Remove("k1")
+	cache.Set("k2", ByteView{bytes: []byte("v2")}, time.Second)
+	clock.Advance(2 * time.Second)
+	cache.Get("k2")
+	for index := 0; index < 10; index++ {
+		cache.Set("c"+strconv.Itoa(index), ByteView{bytes: []byte("v")}, 0)
+	}
+
+	expected := map[string][]EvictReason{
+		"k1=old": {EvictReplaced},
+		"k1=new": {EvictRemoved},
+		"k2=v2":  {EvictExpired},
+		"c0=v":   {EvictCapacity},
+	}
+	for key, want := range expected {
+		if got := reasons[key]; len(got) != 1 || got[0] != want[0] {
+			t.Fatalf("expected %s to be evicted with %v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestMemoryUsageMatchesHeap(t *testing.T) {
+	const entry_count = 50000
+	var plain_estimate, plain_measured int64
+	for _, ttl := range []time.Duration{0, time.Hour} {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		cache := NewCache(CacheOptions{Account_overhead: true})
+		for index := 0; index < entry_count; index++ {
+			cache.Set("key"+strconv.Itoa(index), ByteView{bytes: make([]byte, 16)}, ttl)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(cache)
+
+		usage := cache.MemoryUsage()
+		if usage.Entries != entry_count {
+			t.Fatalf("ttl %v: expected %d entries, got %d", ttl, entry_count, usage.Entries)
+		}
+		if ttl == 0 && usage.TotalBytes != cache.Bytes() {
+			t.Fatalf("expected charged bytes %d to match total %d", cache.Bytes(), usage.TotalBytes)
+		}
+		measured := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+		if usage.TotalBytes < measured*3/4 || usage.TotalBytes > measured*5/4 {
+			t.Fatalf("ttl %v: expected estimate %d within 25%% of heap growth %d", ttl, usage.TotalBytes, measured)
+		}
+		if usage.PayloadBytes*3 > measured {
+			t.Fatalf("ttl %v: expected payload %d to be well below heap growth %d", ttl, usage.PayloadBytes, measured)
+		}
+		if ttl == 0 {
+			plain_estimate, plain_measured = usage.TotalBytes, measured
+			continue
+		}
+		// The ttl bookkeeping alone must be accounted for, not hidden by the
+		// slack above.
+		estimate_delta, measured_delta := usage.TotalBytes-plain_estimate, measured-plain_measured
+		if estimate_delta < measured_delta*3/4 || estimate_delta > measured_delta*5/4 {
+			t.Fatalf("expected ttl overhead %d within 25%% of heap growth %d", estimate_delta, measured_delta)
+		}
+	}
+}
+
+func TestMemoryUsageCountsGhosts(t *testing.T) {
+	cache := NewCache(CacheOptions{Store_type: "arc", Max_bytes: 1 << 10})
+	for index := 0; index < 1000; index++ {
+		key := "key" + strconv.Itoa(index)
+		cache.Set(key, ByteView{bytes: make([]byte, 16)}, 0)
+		cache.Get(key) // promoted to T2, so evictions leave B2 ghosts
+	}
+	usage := cache.MemoryUsage()
+	if resident := int64(usage.Entries) * cache.overhead; usage.OverheadBytes <= resident {
+		t.Fatalf("expected ghost entries on top of resident overhead %d, got %d", resident, usage.OverheadBytes)
+	}
+}
+
+func TestOverheadAccountingTightensLimit(t *testing.T) {
+	plain := NewCache(CacheOptions{Max_bytes: 64 << 10})
+	accounted := NewCache(CacheOptions{Max_bytes: 64 << 10, Account_overhead: true})
+	for index := 0; index < 10000; index++ {
+		key := "k" + strconv.Itoa(index)
+		plain.Set(key, ByteView{bytes: []byte("v")}, 0)
+		accounted.Set(key, ByteView{bytes: []byte("v")}, 0)
+	}
+	if accounted.Bytes() > 64<<10 {
+		t.Fatalf("expected accounted bytes within limit, got %d", accounted.Bytes())
+	}
+	if accounted.Len()*3 > plain.Len() {
+		t.Fatalf("expected overhead to cut entries well below %d, got %d", plain.Len(), accounted.Len())
+	}
+	if usage := plain.MemoryUsage(); usage.OverheadBytes == 0 || usage.PayloadBytes != plain.Bytes() {
+		t.Fatalf("expected overhead estimate without charging it, got %+v", usage)
+	}
+}
+
+func TestCacheSetMaxBytesWithoutResizer(t *testing.T) {
+	cache := NewCache(CacheOptions{Max_bytes: 1 << 20, Shard_count: 2})
+	cache.shards[1].store = struct{ store.Store }{cache.shards[1].store}
+	if error_value := cache.SetMaxBytes(100); !errors.Is(error_value, ErrResizeUnsupported) {
+		t.Fatalf("expected ErrResizeUnsupported, got %v", error_value)
+	}
+	if cache.shards[0].max_bytes != 1<<19 {
+		t.Fatalf("expected no shard to be resized, got %d", cache.shards[0].max_bytes)
+	}
+}
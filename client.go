@@ -2,8 +2,9 @@ package lru_cache
 
 import (
 	"context"
-	"errors"
+	"sort"
 	"sync"
+	"time"
 
 	"lru_cache/consistenthash"
 	"lru_cache/pb"
@@ -38,17 +39,113 @@ func NewClient(address string) (*Client, error) {
 
 // Get fetches data from remote peer.
 func (client *Client) Get(request_context context.Context, group_name string, key string) ([]byte, error) {
-	response, error_value := client.grpc_client.Get(request_context, &pb.GetRequest{Group: group_name, Key: key})
+	bytes, _, error_value := client.GetWithMeta(request_context, group_name, key)
+	return bytes, error_value
+}
+
+// GetWithMeta fetches data from remote peer along with the owner's remaining
+// TTL and caching hints.
+func (client *Client) GetWithMeta(request_context context.Context, group_name string, key string) ([]byte, EntryMeta, error) {
+	return client.get_with_meta(request_context, group_name, key, false)
+}
+
+// get_with_meta asks the peer to serve key itself, without forwarding, when
+// local is set.
+func (client *Client) get_with_meta(request_context context.Context, group_name string, key string, local bool) ([]byte, EntryMeta, error) {
+	response, error_value := client.grpc_client.Get(request_context, &pb.GetRequest{Group: group_name, Key: key, Local: local})
 	if error_value != nil {
-		return nil, error_value
+		return nil, EntryMeta{}, error_value
 	}
-	if response.Err != "" {
-		if response.Err == ErrNotFound.Error() {
-			return nil, ErrNotFound
+	meta := EntryMeta{TTL: time.Duration(response.TTLMillis) * time.Millisecond, NoCache: response.NoCache}
+	if error_value := response_error(response.Err); error_value != nil {
+		if !response.Tombstone {
+			meta = EntryMeta{}
 		}
-		return nil, errors.New(response.Err)
+		return nil, meta, error_value
+	}
+	return response.Value, meta, nil
+}
+
+// MultiGet fetches several keys from a remote peer in one call.
+func (client *Client) MultiGet(request_context context.Context, group_name string, keys []string) ([]GetResult, error) {
+	return client.multi_get(request_context, group_name, keys, false)
+}
+
+func (client *Client) multi_get(request_context context.Context, group_name string, keys []string, local bool) ([]GetResult, error) {
+	response, error_value := client.grpc_client.MultiGet(request_context, &pb.MultiGetRequest{Group: group_name, Keys: keys, Local: local})
+	if error_value != nil {
+		return nil, error_value
+	}
+	if error_value := response_error(response.Err); error_value != nil {
+		return nil, error_value
+	}
+	results := make([]GetResult, 0, len(response.Entries))
+	for _, entry := range response.Entries {
+		results = append(results, GetResult{
+			Key:   entry.Key,
+			Value: ByteView{bytes: entry.Value},
+			Meta:  EntryMeta{TTL: time.Duration(entry.TTLMillis) * time.Millisecond, NoCache: entry.NoCache},
+			Err:   response_error(entry.Err),
+		})
+	}
+	return results, nil
+}
+
+// Delete removes a key from a remote peer.
+func (client *Client) Delete(request_context context.Context, group_name string, key string) error {
+	response, error_value := client.grpc_client.Delete(request_context, &pb.DeleteRequest{Group: group_name, Key: key})
+	if error_value != nil {
+		return error_value
+	}
+	return response_error(response.Err)
+}
+
+// Set stores a value on a remote peer (a zero ttl uses the group default).
+func (client *Client) Set(request_context context.Context, group_name string, key string, value []byte, ttl time.Duration) error {
+	response, error_value := client.grpc_client.Set(request_context, &pb.SetRequest{
+		Group:     group_name,
+		Key:       key,
+		Value:     value,
+		TTLMillis: ttl_to_millis(ttl),
+	})
+	if error_value != nil {
+		return error_value
+	}
+	return response_error(response.Err)
+}
+
+// Stats fetches statistics for a group on a remote peer, or for all of its
+// groups when group_name is empty.
+func (client *Client) Stats(request_context context.Context, group_name string) ([]GroupStats, error) {
+	response, error_value := client.grpc_client.Stats(request_context, &pb.StatsRequest{Group: group_name})
+	if error_value != nil {
+		return nil, error_value
+	}
+	if error_value := response_error(response.Err); error_value != nil {
+		return nil, error_value
+	}
+	stats := make([]GroupStats, 0, len(response.Groups))
+	for _, group_stats := range response.Groups {
+		stats = append(stats, GroupStats{
+			Name:       group_stats.Group,
+			Hits:       group_stats.Hits,
+			Misses:     group_stats.Misses,
+			Bytes:      group_stats.Bytes,
+			Entries:    int(group_stats.Entries),
+			HotHits:    group_stats.HotHits,
+			HotMisses:  group_stats.HotMisses,
+			HotBytes:   group_stats.HotBytes,
+			HotEntries: int(group_stats.HotEntries),
+
+			Evictions:     group_stats.Evictions,
+			Expirations:   group_stats.Expirations,
+			NegativeHits:  group_stats.NegativeHits,
+			StaleServed:   group_stats.StaleServed,
+			Refreshes:     group_stats.Refreshes,
+			RefreshErrors: group_stats.RefreshErrors,
+		})
 	}
-	return response.Value, nil
+	return stats, nil
 }
 
 // Close closes the client connection.
@@ -63,10 +160,13 @@ func (client *Client) Close() error {
 type ClientPicker struct {
 	self_address  string
 	replica_count int
+	load_factor   float64
+	new_router    func() consistenthash.PeerRouter
 
 	mutex        sync.RWMutex
-	hash_ring    *consistenthash.Map
+	hash_ring    consistenthash.PeerRouter
 	peer_clients map[string]*Client
+	tracked      map[string]*load_tracking_client // load-counting clients, set when load_factor > 0
 }
 
 // NewClientPicker creates a picker with default replicas.
@@ -79,16 +179,66 @@ func (picker *ClientPicker) SetReplicas(replica_count int) {
 	picker.replica_count = replica_count
 }
 
+// SetRouter selects how keys map to peers, for example
+// func() consistenthash.PeerRouter { return consistenthash.NewMaglev(0) }.
+// The default is a ring with SetReplicas virtual nodes per peer. Call it
+// before SetPeers.
+//
+// Peers are added in sorted address order, so every picker given the same
+// peers builds the same router. With Jump, which maps keys by list position,
+// a peer joining or leaving anywhere but the end of that order remaps most
+// keys; prefer another router where membership changes often.
+func (picker *ClientPicker) SetRouter(new_router func() consistenthash.PeerRouter) {
+	picker.new_router = new_router
+}
+
+// SetLoadFactor turns on bounded-load picking: no peer gets more than factor
+// times the average number of in-flight requests, and keys owned by a busy
+// peer go to the next one on the ring. Only requests sent through this picker
+// are counted, so keys never spill onto this node itself, and only routers
+// implementing consistenthash.LoadBalancer support it. Call it before
+// SetPeers.
+func (picker *ClientPicker) SetLoadFactor(factor float64) {
+	picker.load_factor = factor
+}
+
 // SetPeers replaces the peer list.
 func (picker *ClientPicker) SetPeers(peer_addresses ...string) {
+	weights := make(map[string]int, len(peer_addresses))
+	for _, address := range peer_addresses {
+		weights[address] = 1
+	}
+	picker.SetWeightedPeers(weights)
+}
+
+// SetWeightedPeers replaces the peer list, giving each peer a share of the
+// keyspace proportional to its weight, for example its memory in GB. Weights
+// are ignored by routers that do not implement consistenthash.WeightedRouter.
+func (picker *ClientPicker) SetWeightedPeers(weights map[string]int) {
 	picker.mutex.Lock()
 	defer picker.mutex.Unlock()
 
-	hash_ring := consistenthash.New(picker.replica_count, nil)
-	hash_ring.Add(peer_addresses...)
+	hash_ring := picker.router()
+	load_balancer, balanced := hash_ring.(consistenthash.LoadBalancer)
+	if balanced && picker.load_factor > 0 {
+		load_balancer.SetLoadFactor(picker.load_factor)
+		load_balancer.SetLocal(picker.self_address)
+	}
+	addresses := make([]string, 0, len(weights))
+	for address := range weights {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		if weighted_router, ok := hash_ring.(consistenthash.WeightedRouter); ok {
+			weighted_router.AddWeighted(address, weights[address])
+		} else {
+			hash_ring.Add(address)
+		}
+	}
 
 	new_peer_clients := make(map[string]*Client)
-	for _, address := range peer_addresses {
+	for address := range weights {
 		if address == "" {
 			continue
 		}
@@ -109,6 +259,20 @@ func (picker *ClientPicker) SetPeers(peer_addresses ...string) {
 	}
 	picker.hash_ring = hash_ring
 	picker.peer_clients = new_peer_clients
+	picker.tracked = nil
+	if balanced && picker.load_factor > 0 {
+		picker.tracked = make(map[string]*load_tracking_client, len(new_peer_clients))
+		for address, client := range new_peer_clients {
+			picker.tracked[address] = &load_tracking_client{Client: client, load_balancer: load_balancer, address: address}
+		}
+	}
+}
+
+func (picker *ClientPicker) router() consistenthash.PeerRouter {
+	if picker.new_router != nil {
+		return picker.new_router()
+	}
+	return consistenthash.New(picker.replica_count, nil)
 }
 
 // PickPeer returns a peer for the given key.
@@ -122,10 +286,63 @@ func (picker *ClientPicker) PickPeer(key string) (PeerGetter, bool) {
 	if peer_address == "" || peer_address == picker.self_address {
 		return nil, false
 	}
-	client, ok := picker.peer_clients[peer_address]
+	return picker.peer(peer_address)
+}
+
+// PickPeers returns up to n remote owners of key in preference order and this
+// node's rank among the owners, or -1. Routers that cannot name backups only
+// return the primary. Bounded loads are not applied, so the primary is the
+// key's owner even while PickPeer spills the key to another peer.
+func (picker *ClientPicker) PickPeers(key string, n int) ([]PeerGetter, int) {
+	picker.mutex.RLock()
+	defer picker.mutex.RUnlock()
+	if picker.hash_ring == nil || len(picker.peer_clients) == 0 {
+		return nil, -1
+	}
+	var addresses []string
+	if replica_router, ok := picker.hash_ring.(consistenthash.ReplicaRouter); ok {
+		addresses = replica_router.GetN(key, n)
+	} else if n > 0 {
+		addresses = []string{picker.hash_ring.Get(key)}
+	}
+	peers := make([]PeerGetter, 0, len(addresses))
+	self_rank := -1
+	for rank, address := range addresses {
+		if address == picker.self_address {
+			self_rank = rank
+			continue
+		}
+		if peer_getter, ok := picker.peer(address); ok {
+			peers = append(peers, peer_getter)
+		}
+	}
+	return peers, self_rank
+}
+
+func (picker *ClientPicker) peer(address string) (PeerGetter, bool) {
+	if picker.tracked != nil {
+		client, ok := picker.tracked[address]
+		return client, ok
+	}
+	client, ok := picker.peer_clients[address]
 	return client, ok
 }
 
+// ListPeers returns every remote peer client.
+func (picker *ClientPicker) ListPeers() []PeerGetter {
+	picker.mutex.RLock()
+	defer picker.mutex.RUnlock()
+	peers := make([]PeerGetter, 0, len(picker.peer_clients))
+	for address := range picker.peer_clients {
+		if address == picker.self_address {
+			continue
+		}
+		peer_getter, _ := picker.peer(address)
+		peers = append(peers, peer_getter)
+	}
+	return peers
+}
+
 // Close closes all clients.
 func (picker *ClientPicker) Close() {
 	picker.mutex.Lock()
@@ -134,5 +351,45 @@ func (picker *ClientPicker) Close() {
 		_ = client.Close()
 	}
 	picker.peer_clients = nil
+	picker.tracked = nil
 	picker.hash_ring = nil
 }
+
+// load_tracking_client counts each request to a peer as load on the ring
+// while it is in flight. The picker has already balanced the key, so the
+// peer is asked to serve it itself rather than forward it to the owner.
+type load_tracking_client struct {
+	*Client
+	load_balancer consistenthash.LoadBalancer
+	address       string
+}
+
+func (client *load_tracking_client) Get(request_context context.Context, group_name string, key string) ([]byte, error) {
+	client.load_balancer.Inc(client.address)
+	defer client.load_balancer.Done(client.address)
+	bytes, _, error_value := client.Client.get_with_meta(request_context, group_name, key, true)
+	return bytes, error_value
+}
+
+func (client *load_tracking_client) GetWithMeta(request_context context.Context, group_name string, key string) ([]byte, EntryMeta, error) {
+	client.load_balancer.Inc(client.address)
+	defer client.load_balancer.Done(client.address)
+	return client.Client.get_with_meta(request_context, group_name, key, true)
+}
+
+func (client *load_tracking_client) MultiGet(request_context context.Context, group_name string, keys []string) ([]GetResult, error) {
+	client.load_balancer.Inc(client.address)
+	defer client.load_balancer.Done(client.address)
+	return client.Client.multi_get(request_context, group_name, keys, true)
+}
+
+// response_error maps the error string of a peer response back to an error.
+func response_error(message string) error {
+	if message == "" {
+		return nil
+	}
+	if message == ErrNotFound.Error() {
+		return ErrNotFound
+	}
+	return &RemoteError{Message: message}
+}
@@ -0,0 +1,150 @@
+package lru_cache
+
+import (
+	"strconv"
+	"testing"
+
+	"lru_cache/consistenthash"
+)
+
+func TestClientPickerBoundedLoad(t *testing.T) {
+	picker := NewClientPicker("self:0")
+	picker.SetLoadFactor(1)
+	picker.SetPeers("self:0", "peer-a:1", "peer-b:2")
+	defer picker.Close()
+
+	var key string
+	var owner PeerGetter
+	for index := 0; owner == nil; index++ {
+		key = "key" + strconv.Itoa(index)
+		owner, _ = picker.PickPeer(key)
+	}
+	tracked, ok := owner.(*load_tracking_client)
+	if !ok {
+		t.Fatalf("expected a load-tracking peer, got %T", owner)
+	}
+	for _, listed := range picker.ListPeers() {
+		if listed.(*load_tracking_client).address == tracked.address && listed != owner {
+			t.Fatalf("expected ListPeers to return the same peer values as PickPeer")
+		}
+	}
+
+	tracked.load_balancer.Inc(tracked.address)
+	if next, _ := picker.PickPeer(key); next == owner {
+		t.Fatalf("expected a busy peer to be skipped")
+	}
+	tracked.load_balancer.Done(tracked.address)
+	if next, _ := picker.PickPeer(key); next != owner {
+		t.Fatalf("expected the owner back once idle")
+	}
+}
+
+func TestClientPickerBoundedLoadSkipsSelf(t *testing.T) {
+	picker := NewClientPicker("self:0")
+	picker.SetLoadFactor(1)
+	picker.SetPeers("self:0", "peer-a:1", "peer-b:2")
+	defer picker.Close()
+
+	var self_key, peer_key string
+	var owner PeerGetter
+	for index := 0; self_key == "" || owner == nil; index++ {
+		key := "key" + strconv.Itoa(index)
+		if peer_getter, ok := picker.PickPeer(key); !ok {
+			self_key = key
+		} else if owner == nil {
+			peer_key, owner = key, peer_getter
+		}
+	}
+	load_balancer := owner.(*load_tracking_client).load_balancer
+	for _, address := range []string{"peer-a:1", "peer-b:2"} {
+		load_balancer.Inc(address)
+		defer load_balancer.Done(address)
+	}
+	if _, ok := picker.PickPeer(self_key); ok {
+		t.Fatalf("expected self to keep serving its own key")
+	}
+	if next, ok := picker.PickPeer(peer_key); !ok || next != owner {
+		t.Fatalf("expected a key owned by a peer not to spill to self, got %v %v", next, ok)
+	}
+}
+func TestClientPickerWeightedPeers(t *testing.T) {
+	picker := NewClientPicker("self:0")
+	picker.SetWeightedPeers(map[string]int{"self:0": 1, "small:1": 1, "large:2": 4})
+	defer picker.Close()
+
+	counts := make(map[string]int)
+	for index := 0; index < 60000; index++ {
+		peer_getter, ok := picker.PickPeer("key" + strconv.Itoa(index))
+		if !ok {
+			counts["self:0"]++
+			continue
+		}
+		for address, client := range picker.peer_clients {
+			if client == peer_getter {
+				counts[address]++
+			}
+		}
+	}
+	if counts["large:2"] < 3*counts["small:1"] || counts["large:2"] < 3*counts["self:0"] {
+		t.Fatalf("expected the weight-4 peer to own about 4x the keys, got %v", counts)
+	}
+}
+
+func TestClientPickerRouterSelection(t *testing.T) {
+	for _, new_router := range []func() consistenthash.PeerRouter{
+		func() consistenthash.PeerRouter { return consistenthash.NewRendezvous() },
+		func() consistenthash.PeerRouter { return consistenthash.NewMaglev(0) },
+		func() consistenthash.PeerRouter { return consistenthash.NewJump() },
+	} {
+		picker := NewClientPicker("self:0")
+		picker.SetRouter(new_router)
+		picker.SetLoadFactor(1.25)
+		picker.SetPeers("self:0", "peer-a:1", "peer-b:2")
+
+		remote := 0
+		for index := 0; index < 300; index++ {
+			if peer_getter, ok := picker.PickPeer("key" + strconv.Itoa(index)); ok {
+				if _, tracked := peer_getter.(*load_tracking_client); tracked {
+					t.Fatalf("%T: expected no load tracking without a LoadBalancer", picker.hash_ring)
+				}
+				remote++
+			}
+		}
+		if remote < 150 || remote > 250 {
+			t.Fatalf("%T: expected about 2/3 of keys on remote peers, got %d of 300", picker.hash_ring, remote)
+		}
+		picker.Close()
+	}
+}
+
+func TestClientPickerPickPeers(t *testing.T) {
+	picker := NewClientPicker("self:0")
+	picker.SetPeers("self:0", "peer-a:1", "peer-b:2", "peer-c:3")
+	defer picker.Close()
+
+	for index := 0; index < 200; index++ {
+		key := "key" + strconv.Itoa(index)
+		peers, self_rank := picker.PickPeers(key, 2)
+		owners := picker.hash_ring.(consistenthash.ReplicaRouter).GetN(key, 2)
+		expected, expected_rank := 2, -1
+		for rank, owner := range owners {
+			if owner == "self:0" {
+				expected, expected_rank = expected-1, rank
+			}
+		}
+		if len(peers) != expected || self_rank != expected_rank {
+			t.Fatalf("expected %d remote owners and rank %d for %s (%v), got %d and %d", expected, expected_rank, key, owners, len(peers), self_rank)
+		}
+		if primary, ok := picker.PickPeer(key); ok && peers[0] != primary {
+			t.Fatalf("expected PickPeers to start with the primary")
+		}
+	}
+
+	picker.SetRouter(func() consistenthash.PeerRouter {
+		return struct{ consistenthash.PeerRouter }{consistenthash.NewRendezvous()}
+	})
+	picker.SetPeers("peer-a:1", "peer-b:2")
+	if peers, _ := picker.PickPeers("key", 2); len(peers) != 1 {
+		t.Fatalf("expected routers without GetN to return only the primary, got %d", len(peers))
+	}
+}
@@ -0,0 +1,64 @@
+package lru_cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec converts typed values to and from the bytes a Group stores and sends
+// between peers. Every node serving a group must use the same codec.
+type Codec[T any] interface {
+	Encode(value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec encodes values with encoding/json.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	error_value := json.Unmarshal(data, &value)
+	return value, error_value
+}
+
+// GobCodec encodes values with encoding/gob. Each value carries its own type
+// description, so it suits larger structs better than small scalars.
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Encode(value T) ([]byte, error) {
+	var buffer bytes.Buffer
+	if error_value := gob.NewEncoder(&buffer).Encode(value); error_value != nil {
+		return nil, error_value
+	}
+	return buffer.Bytes(), nil
+}
+
+func (GobCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	error_value := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, error_value
+}
+
+// ProtoCodec encodes generated protobuf messages, for example
+// ProtoCodec[*pb.User].
+type ProtoCodec[T proto.Message] struct{}
+
+func (ProtoCodec[T]) Encode(value T) ([]byte, error) {
+	return proto.Marshal(value)
+}
+
+func (ProtoCodec[T]) Decode(data []byte) (T, error) {
+	var zero T
+	value := zero.ProtoReflect().New().Interface().(T)
+	if error_value := proto.Unmarshal(data, value); error_value != nil {
+		return zero, error_value
+	}
+	return value, nil
+}
@@ -2,18 +2,32 @@ package consistenthash
 
 import (
 	"hash/crc32"
+	"math"
 	"sort"
 	"strconv"
+	"sync/atomic"
 )
 
 type Hash func(data []byte) uint32
 
-// Map implements consistent hashing.
+// Map implements consistent hashing. With a load factor set it implements
+// consistent hashing with bounded loads: no node takes more than factor times
+// its weighted share of the load reported through Inc and Done, and keys
+// whose node is full walk forward to the next node with room.
+//
+// Add, AddWeighted, Remove, Set, SetLoadFactor and SetLocal must not run
+// concurrently with other calls; Get, Inc, Done and Load may run
+// concurrently with each other.
 type Map struct {
 	hash_function Hash
 	replica_count int
 	sorted_keys   []int
 	hash_map      map[int]string
+	node_replicas map[string]int // virtual nodes per weighted node
+	load_factor   float64
+	local_node    string // the caller's own node, never given spilled keys
+	loads         map[string]*int64
+	total_load    atomic.Int64
 }
 
 // New creates a new Map.
@@ -22,6 +36,8 @@ func New(replica_count int, hash_function Hash) *Map {
 		replica_count: replica_count,
 		hash_function: hash_function,
 		hash_map:      make(map[int]string),
+		node_replicas: make(map[string]int),
+		loads:         make(map[string]*int64),
 	}
 	if hash_map.hash_function == nil {
 		hash_map.hash_function = crc32.ChecksumIEEE
@@ -32,24 +48,58 @@ func New(replica_count int, hash_function Hash) *Map {
 // Add adds keys to the hash.
 func (hash_map *Map) Add(keys ...string) {
 	for _, key := range keys {
-		for replica_index := 0; replica_index < hash_map.replica_count; replica_index++ {
-			hash_value := int(hash_map.hash_function([]byte(strconv.Itoa(replica_index) + key)))
-			hash_map.sorted_keys = append(hash_map.sorted_keys, hash_value)
-			hash_map.hash_map[hash_value] = key
-		}
+		hash_map.add(key, hash_map.replica_count)
 	}
 	sort.Ints(hash_map.sorted_keys)
 }
 
+// AddWeighted adds node with weight times the virtual nodes of Add, so it
+// owns a share of the keyspace proportional to its weight. A weight of 1 is
+// the same as Add; weights below 1 are raised to 1.
+func (hash_map *Map) AddWeighted(node string, weight int) {
+	hash_map.add(node, hash_map.replica_count*max(weight, 1))
+	sort.Ints(hash_map.sorted_keys)
+}
+
+// add places replica_count virtual nodes for key, replacing any it already
+// had. The caller sorts sorted_keys afterwards.
+func (hash_map *Map) add(key string, replica_count int) {
+	if _, ok := hash_map.node_replicas[key]; ok {
+		hash_map.remove_replicas(key)
+	}
+	hash_map.node_replicas[key] = replica_count
+	for replica_index := 0; replica_index < replica_count; replica_index++ {
+		hash_value := int(hash_map.hash_function([]byte(strconv.Itoa(replica_index) + key)))
+		hash_map.sorted_keys = append(hash_map.sorted_keys, hash_value)
+		hash_map.hash_map[hash_value] = key
+	}
+	if _, ok := hash_map.loads[key]; !ok {
+		hash_map.loads[key] = new(int64)
+	}
+}
+
 // Remove removes keys from the hash.
 func (hash_map *Map) Remove(keys ...string) {
-	if len(keys) == 0 || len(hash_map.sorted_keys) == 0 {
+	for _, key := range keys {
+		hash_map.remove_replicas(key)
+		if load, ok := hash_map.loads[key]; ok {
+			hash_map.total_load.Add(-atomic.LoadInt64(load))
+			delete(hash_map.loads, key)
+		}
+	}
+}
+
+// remove_replicas drops the virtual nodes of key, however many it was given.
+func (hash_map *Map) remove_replicas(key string) {
+	replica_count, ok := hash_map.node_replicas[key]
+	if !ok {
 		return
 	}
-	remove := make(map[int]struct{})
-	for _, key := range keys {
-		for replica_index := 0; replica_index < hash_map.replica_count; replica_index++ {
-			hash_value := int(hash_map.hash_function([]byte(strconv.Itoa(replica_index) + key)))
+	delete(hash_map.node_replicas, key)
+	remove := make(map[int]struct{}, replica_count)
+	for replica_index := 0; replica_index < replica_count; replica_index++ {
+		hash_value := int(hash_map.hash_function([]byte(strconv.Itoa(replica_index) + key)))
+		if hash_map.hash_map[hash_value] == key {
 			remove[hash_value] = struct{}{}
 			delete(hash_map.hash_map, hash_value)
 		}
@@ -67,18 +117,128 @@ func (hash_map *Map) Remove(keys ...string) {
 func (hash_map *Map) Set(keys []string) {
 	hash_map.sorted_keys = nil
 	hash_map.hash_map = make(map[int]string)
+	hash_map.node_replicas = make(map[string]int)
+	hash_map.loads = make(map[string]*int64)
+	hash_map.total_load.Store(0)
 	hash_map.Add(keys...)
 }
 
-// Get returns the closest item in the hash to the provided key.
-func (hash_map *Map) Get(key string) string {
-	if len(hash_map.sorted_keys) == 0 {
-		return ""
+// SetLoadFactor enables bounded loads with factor (for example 1.25); 0
+// turns it off. Factors between 0 and 1 are raised to 1.
+func (hash_map *Map) SetLoadFactor(factor float64) {
+	if factor > 0 && factor < 1 {
+		factor = 1
+	}
+	hash_map.load_factor = factor
+}
+
+// SetLocal marks node as the caller itself. Requests it serves are not seen
+// through Inc, so with a load factor it keeps the keys it owns but is skipped
+// when keys spill off a full node.
+func (hash_map *Map) SetLocal(node string) {
+	hash_map.local_node = node
+}
+
+// Inc records one more unit of load, such as an in-flight request, on node.
+func (hash_map *Map) Inc(node string) {
+	if load, ok := hash_map.loads[node]; ok {
+		atomic.AddInt64(load, 1)
+		hash_map.total_load.Add(1)
+	}
+}
+
+// Done releases a unit of load recorded by Inc.
+func (hash_map *Map) Done(node string) {
+	if load, ok := hash_map.loads[node]; ok {
+		atomic.AddInt64(load, -1)
+		hash_map.total_load.Add(-1)
+	}
+}
+
+// Load returns the current load of node.
+func (hash_map *Map) Load(node string) int64 {
+	if load, ok := hash_map.loads[node]; ok {
+		return atomic.LoadInt64(load)
+	}
+	return 0
+}
+
+// capacity is the most load node may carry before keys skip it, counting
+// the request about to be placed. Weighted nodes get a proportional share.
+// The local node carries no counted load, so shares are taken over the
+// other nodes' virtual nodes only.
+func (hash_map *Map) capacity(node string, total_load int64) int64 {
+	counted_replicas := len(hash_map.sorted_keys) - hash_map.node_replicas[hash_map.local_node]
+	if counted_replicas <= 0 {
+		return math.MaxInt64
+	}
+	share := float64(hash_map.node_replicas[node]) / float64(counted_replicas)
+	return int64(math.Ceil(hash_map.load_factor * float64(total_load+1) * share))
+}
+
+// GetN returns up to n distinct nodes for key in ring order, starting with
+// the node Get would return without a load factor. Later nodes are the
+// natural backups when the first is down. Loads are not considered, so with
+// a load factor the first node may differ from what Get returns.
+func (hash_map *Map) GetN(key string, n int) []string {
+	if len(hash_map.sorted_keys) == 0 || n <= 0 {
+		return nil
+	}
+	n = min(n, len(hash_map.node_replicas))
+	nodes := make([]string, 0, n)
+	index := hash_map.search(key)
+	for step := 0; step < len(hash_map.sorted_keys) && len(nodes) < n; step++ {
+		node := hash_map.hash_map[hash_map.sorted_keys[(index+step)%len(hash_map.sorted_keys)]]
+		if !contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
 	}
+	return nodes
+}
+
+// search returns the index of the first virtual node at or after key.
+func (hash_map *Map) search(key string) int {
 	hash_value := int(hash_map.hash_function([]byte(key)))
 	index := sort.Search(len(hash_map.sorted_keys), func(i int) bool { return hash_map.sorted_keys[i] >= hash_value })
 	if index == len(hash_map.sorted_keys) {
 		index = 0
 	}
+	return index
+}
+
+func contains(nodes []string, node string) bool {
+	for _, existing := range nodes {
+		if existing == node {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the closest item in the hash to the provided key.
+func (hash_map *Map) Get(key string) string {
+	if len(hash_map.sorted_keys) == 0 {
+		return ""
+	}
+	index := hash_map.search(key)
+	if hash_map.load_factor == 0 {
+		return hash_map.hash_map[hash_map.sorted_keys[index]]
+	}
+
+	total_load := hash_map.total_load.Load()
+	for step := 0; step < len(hash_map.sorted_keys); step++ {
+		node := hash_map.hash_map[hash_map.sorted_keys[(index+step)%len(hash_map.sorted_keys)]]
+		if node == hash_map.local_node {
+			if step == 0 {
+				return node
+			}
+			continue
+		}
+		if atomic.LoadInt64(hash_map.loads[node]) < hash_map.capacity(node, total_load) {
+			return node
+		}
+	}
+	// Concurrent Inc calls can fill every node between reads; fall back to
+	// the unbounded owner.
 	return hash_map.hash_map[hash_map.sorted_keys[index]]
 }
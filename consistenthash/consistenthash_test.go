@@ -1,6 +1,10 @@
 package consistenthash
 
-import "testing"
+import (
+	"math"
+	"strconv"
+	"testing"
+)
 
 func TestConsistentHashBasic(t *testing.T) {
 	hash_ring := New(3, nil)
@@ -17,3 +21,155 @@ func TestConsistentHashBasic(t *testing.T) {
 		t.Fatalf("expected key1 to map to nodeB after removing nodeA, got %s", value)
 	}
 }
+
+func TestBoundedLoadWalksPastFullNodes(t *testing.T) {
+	hash_ring := New(10, nil)
+	hash_ring.SetLoadFactor(1.25)
+	hash_ring.Add("nodeA", "nodeB", "nodeC")
+
+	owner := hash_ring.Get("hot")
+	for index := 0; index < 2; index++ {
+		hash_ring.Inc(owner)
+	}
+	if next := hash_ring.Get("hot"); next == owner {
+		t.Fatalf("expected hot key to skip overloaded %s", owner)
+	}
+	hash_ring.Done(owner)
+	hash_ring.Done(owner)
+	if hash_ring.Get("hot") != owner || hash_ring.Load(owner) != 0 {
+		t.Fatalf("expected the key to return to %s once its load is released", owner)
+	}
+}
+
+func TestBoundedLoadSkipsLocalNode(t *testing.T) {
+	hash_ring := New(10, nil)
+	hash_ring.SetLoadFactor(1)
+	hash_ring.SetLocal("nodeA")
+	hash_ring.Add("nodeA", "nodeB", "nodeC")
+
+	for index := 0; index < 100; index++ {
+		key := "key" + strconv.Itoa(index)
+		owner := hash_ring.GetN(key, 1)[0]
+		hash_ring.Inc("nodeB")
+		hash_ring.Inc("nodeC")
+		node := hash_ring.Get(key)
+		hash_ring.Done("nodeB")
+		hash_ring.Done("nodeC")
+		if owner == "nodeA" && node != "nodeA" {
+			t.Fatalf("%s: expected the local node to keep its own key, got %s", key, node)
+		}
+		if owner != "nodeA" && node == "nodeA" {
+			t.Fatalf("%s: expected a key owned by %s not to spill to the local node", key, owner)
+		}
+	}
+}
+
+func TestBoundedLoadWithLocalNodeKeepsBound(t *testing.T) {
+	const factor = 1.1
+	hash_ring := New(10, nil)
+	hash_ring.SetLoadFactor(factor)
+	hash_ring.SetLocal("nodeA")
+	hash_ring.Add("nodeA", "nodeB", "nodeC")
+
+	placed := 0
+	for index := 0; placed < 100; index++ {
+		key := "key" + strconv.Itoa(index)
+		if hash_ring.GetN(key, 1)[0] != "nodeB" {
+			continue
+		}
+		hash_ring.Inc(hash_ring.Get(key))
+		placed++
+		limit := int64(math.Ceil(factor * float64(placed) / 2))
+		for _, node := range []string{"nodeB", "nodeC"} {
+			if load := hash_ring.Load(node); load > limit {
+				t.Fatalf("after %d keys expected %s within %d, got %d", placed, node, limit, load)
+			}
+		}
+	}
+}
+func TestBoundedLoadCapsEveryNode(t *testing.T) {
+	const factor = 1.25
+	hash_ring := New(50, nil)
+	hash_ring.SetLoadFactor(factor)
+	nodes := []string{"nodeA", "nodeB", "nodeC", "nodeD"}
+	hash_ring.Add(nodes...)
+
+	// Every request hits the same key, the worst case for plain hashing.
+	for index := 0; index < 1000; index++ {
+		hash_ring.Inc(hash_ring.Get("hot"))
+	}
+	limit := int64(math.Ceil(factor * 1000 / float64(len(nodes))))
+	for _, node := range nodes {
+		if load := hash_ring.Load(node); load > limit {
+			t.Fatalf("expected %s load within %d, got %d", node, limit, load)
+		}
+	}
+
+	hash_ring.Remove("nodeA")
+	if hash_ring.Get("hot") == "nodeA" || hash_ring.Load("nodeA") != 0 {
+		t.Fatalf("expected removed node to stop receiving keys")
+	}
+}
+
+func TestAddWeightedDistribution(t *testing.T) {
+	hash_ring := New(100, nil)
+	hash_ring.AddWeighted("small", 1)
+	hash_ring.AddWeighted("medium", 2)
+	hash_ring.AddWeighted("large", 8)
+
+	const key_count = 200000
+	counts := make(map[string]int)
+	for index := 0; index < key_count; index++ {
+		counts[hash_ring.Get("key"+strconv.Itoa(index))]++
+	}
+	for node, weight := range map[string]int{"small": 1, "medium": 2, "large": 8} {
+		expected := float64(key_count) * float64(weight) / 11
+		if share := float64(counts[node]); math.Abs(share-expected) > 0.15*expected {
+			t.Fatalf("expected %s to get about %.0f keys, got %d", node, expected, counts[node])
+		}
+	}
+
+	hash_ring.Remove("large")
+	for index := 0; index < 1000; index++ {
+		if node := hash_ring.Get("key" + strconv.Itoa(index)); node == "large" {
+			t.Fatalf("expected every weighted virtual node to be removed")
+		}
+	}
+	if len(hash_ring.sorted_keys) != 300 {
+		t.Fatalf("expected 300 virtual nodes left, got %d", len(hash_ring.sorted_keys))
+	}
+}
+
+func TestAddWeightedReplacesWeight(t *testing.T) {
+	hash_ring := New(10, nil)
+	hash_ring.AddWeighted("node", 4)
+	hash_ring.AddWeighted("node", 1)
+	if len(hash_ring.sorted_keys) != 10 {
+		t.Fatalf("expected re-adding to replace the virtual nodes, got %d", len(hash_ring.sorted_keys))
+	}
+}
+
+func TestGetNReturnsDistinctSuccessors(t *testing.T) {
+	hash_ring := New(20, nil)
+	hash_ring.Add("nodeA", "nodeB", "nodeC", "nodeD")
+	for index := 0; index < 1000; index++ {
+		key := "key" + strconv.Itoa(index)
+		nodes := hash_ring.GetN(key, 3)
+		if len(nodes) != 3 || nodes[0] != hash_ring.Get(key) {
+			t.Fatalf("expected 3 nodes led by the owner for %s, got %v", key, nodes)
+		}
+		if nodes[0] == nodes[1] || nodes[1] == nodes[2] || nodes[0] == nodes[2] {
+			t.Fatalf("expected distinct nodes, got %v", nodes)
+		}
+	}
+	if nodes := hash_ring.GetN("key", 10); len(nodes) != 4 {
+		t.Fatalf("expected n capped at the node count, got %v", nodes)
+	}
+
+	// A backup becomes the owner when the primary leaves.
+	nodes := hash_ring.GetN("key", 2)
+	hash_ring.Remove(nodes[0])
+	if owner := hash_ring.Get("key"); owner != nodes[1] {
+		t.Fatalf("expected backup %s to take over, got %s", nodes[1], owner)
+	}
+}
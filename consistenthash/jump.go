@@ -0,0 +1,68 @@
+package consistenthash
+
+// Jump implements Lamping and Veach's jump consistent hash. It needs no
+// memory beyond the node list and balances almost perfectly, but it only
+// moves a minimal set of keys when nodes are appended or removed from the
+// end; removing a node from the middle shifts every later bucket. Use it
+// only where every caller sees the same node list in the same order.
+type Jump struct {
+	nodes []string
+}
+
+// NewJump creates an empty Jump router.
+func NewJump() *Jump {
+	return &Jump{}
+}
+
+// Add appends nodes as new buckets.
+func (router *Jump) Add(nodes ...string) {
+	for _, node := range nodes {
+		if router.index(node) < 0 {
+			router.nodes = append(router.nodes, node)
+		}
+	}
+}
+
+// Remove removes nodes, keeping the order of the rest.
+func (router *Jump) Remove(nodes ...string) {
+	for _, node := range nodes {
+		if index := router.index(node); index >= 0 {
+			router.nodes = append(router.nodes[:index], router.nodes[index+1:]...)
+		}
+	}
+}
+
+// Set replaces the nodes. Pass them in a stable order, with new nodes last,
+// to keep movement minimal.
+func (router *Jump) Set(nodes []string) {
+	router.nodes = nil
+	router.Add(nodes...)
+}
+
+// Get returns the bucket chosen by jump hash for key.
+func (router *Jump) Get(key string) string {
+	if len(router.nodes) == 0 {
+		return ""
+	}
+	return router.nodes[jump_hash(hash_string(key), len(router.nodes))]
+}
+
+func (router *Jump) index(node string) int {
+	for index, existing := range router.nodes {
+		if existing == node {
+			return index
+		}
+	}
+	return -1
+}
+
+// jump_hash returns a bucket in [0, bucket_count).
+func jump_hash(key uint64, bucket_count int) int {
+	bucket, next := int64(-1), int64(0)
+	for next < int64(bucket_count) {
+		bucket = next
+		key = key*2862933555777941757 + 1
+		next = int64(float64(bucket+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(bucket)
+}
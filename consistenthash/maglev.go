@@ -0,0 +1,144 @@
+package consistenthash
+
+import "sort"
+
+// default_maglev_size is the lookup table size; it must be prime and much
+// larger than the node count for even balance.
+const default_maglev_size = 65537
+
+// Maglev implements Google's Maglev hashing: nodes take turns claiming slots
+// of a fixed lookup table along their own permutation. Lookups are a single
+// table read, at the cost of rebuilding the table on every change.
+type Maglev struct {
+	table_size int
+	weights    map[string]int
+	table      []string
+}
+
+// NewMaglev creates an empty Maglev router. table_size is rounded up to the
+// next prime, since every node's permutation must visit every slot; 0 uses
+// 65537.
+func NewMaglev(table_size int) *Maglev {
+	if table_size <= 0 {
+		table_size = default_maglev_size
+	}
+	return &Maglev{table_size: next_prime(table_size), weights: make(map[string]int)}
+}
+
+// next_prime returns the smallest prime not below value.
+func next_prime(value int) int {
+	if value <= 2 {
+		return 2
+	}
+	for ; ; value++ {
+		prime := true
+		for divisor := 2; divisor*divisor <= value; divisor++ {
+			if value%divisor == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return value
+		}
+	}
+}
+
+// Add adds nodes with weight 1.
+func (router *Maglev) Add(nodes ...string) {
+	for _, node := range nodes {
+		router.weights[node] = 1
+	}
+	router.populate()
+}
+
+// AddWeighted adds node, or changes its weight. A node with weight w claims
+// w slots per turn.
+func (router *Maglev) AddWeighted(node string, weight int) {
+	router.weights[node] = max(weight, 1)
+	router.populate()
+}
+
+// Remove removes nodes.
+func (router *Maglev) Remove(nodes ...string) {
+	for _, node := range nodes {
+		delete(router.weights, node)
+	}
+	router.populate()
+}
+
+// Set resets and adds the provided nodes.
+func (router *Maglev) Set(nodes []string) {
+	router.weights = make(map[string]int, len(nodes))
+	router.Add(nodes...)
+}
+
+// Get returns the node owning key's table slot.
+func (router *Maglev) Get(key string) string {
+	if len(router.table) == 0 {
+		return ""
+	}
+	return router.table[hash_string(key)%uint64(len(router.table))]
+}
+
+// GetN returns up to n distinct nodes found walking the table forward from
+// key's slot, the owner first. The backups are stable and spread evenly, but
+// they are not necessarily the node that would own key if the owner were
+// removed, since rebuilding the table reassigns slots along every node's
+// permutation.
+func (router *Maglev) GetN(key string, n int) []string {
+	n = min(n, len(router.weights))
+	if len(router.table) == 0 || n <= 0 {
+		return nil
+	}
+	nodes := make([]string, 0, n)
+	slot := hash_string(key) % uint64(len(router.table))
+	for step := 0; step < len(router.table) && len(nodes) < n; step++ {
+		if node := router.table[(slot+uint64(step))%uint64(len(router.table))]; !contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// populate rebuilds the lookup table. Nodes are visited in sorted order so
+// every picker builds the same table from the same set.
+func (router *Maglev) populate() {
+	if len(router.weights) == 0 {
+		router.table = nil
+		return
+	}
+	nodes := make([]string, 0, len(router.weights))
+	for node := range router.weights {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	size := uint64(router.table_size)
+	offsets := make([]uint64, len(nodes))
+	skips := make([]uint64, len(nodes))
+	next := make([]uint64, len(nodes))
+	for index, node := range nodes {
+		node_hash := hash_string(node)
+		offsets[index] = node_hash % size
+		skips[index] = mix64(node_hash)%(size-1) + 1
+	}
+
+	table := make([]string, router.table_size)
+	filled := 0
+	for filled < router.table_size {
+		for index, node := range nodes {
+			for turn := 0; turn < router.weights[node] && filled < router.table_size; turn++ {
+				slot := (offsets[index] + next[index]*skips[index]) % size
+				for table[slot] != "" {
+					next[index]++
+					slot = (offsets[index] + next[index]*skips[index]) % size
+				}
+				table[slot] = node
+				next[index]++
+				filled++
+			}
+		}
+	}
+	router.table = table
+}
@@ -0,0 +1,111 @@
+package consistenthash
+
+import (
+	"math"
+	"sort"
+)
+
+// Rendezvous implements highest random weight hashing: every node scores
+// each key and the highest score wins. Lookups cost O(nodes) but need no
+// virtual nodes, and removing a node only moves the keys it owned.
+type Rendezvous struct {
+	nodes       []string
+	node_hashes []uint64
+	weights     []float64
+}
+
+// NewRendezvous creates an empty Rendezvous router.
+func NewRendezvous() *Rendezvous {
+	return &Rendezvous{}
+}
+
+// Add adds nodes with weight 1.
+func (router *Rendezvous) Add(nodes ...string) {
+	for _, node := range nodes {
+		router.AddWeighted(node, 1)
+	}
+}
+
+// AddWeighted adds node, or changes its weight, using the logarithmic
+// scoring of weighted rendezvous hashing.
+func (router *Rendezvous) AddWeighted(node string, weight int) {
+	weight = max(weight, 1)
+	for index, existing := range router.nodes {
+		if existing == node {
+			router.weights[index] = float64(weight)
+			return
+		}
+	}
+	router.nodes = append(router.nodes, node)
+	router.node_hashes = append(router.node_hashes, hash_string(node))
+	router.weights = append(router.weights, float64(weight))
+}
+
+// Remove removes nodes.
+func (router *Rendezvous) Remove(nodes ...string) {
+	for _, node := range nodes {
+		for index, existing := range router.nodes {
+			if existing == node {
+				router.nodes = append(router.nodes[:index], router.nodes[index+1:]...)
+				router.node_hashes = append(router.node_hashes[:index], router.node_hashes[index+1:]...)
+				router.weights = append(router.weights[:index], router.weights[index+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Set resets and adds the provided nodes.
+func (router *Rendezvous) Set(nodes []string) {
+	router.nodes, router.node_hashes, router.weights = nil, nil, nil
+	router.Add(nodes...)
+}
+
+// Get returns the node with the highest score for key.
+func (router *Rendezvous) Get(key string) string {
+	best_index := router.best(hash_string(key))
+	if best_index < 0 {
+		return ""
+	}
+	return router.nodes[best_index]
+}
+
+// GetN returns up to n nodes for key in descending score order.
+func (router *Rendezvous) GetN(key string, n int) []string {
+	n = min(n, len(router.nodes))
+	if n <= 0 {
+		return nil
+	}
+	key_hash := hash_string(key)
+	scores := make([]float64, len(router.nodes))
+	order := make([]int, len(router.nodes))
+	for index, node_hash := range router.node_hashes {
+		scores[index] = router.score(key_hash, index, node_hash)
+		order[index] = index
+	}
+	sort.Slice(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+	nodes := make([]string, n)
+	for index := range nodes {
+		nodes[index] = router.nodes[order[index]]
+	}
+	return nodes
+}
+
+// best returns the index of the highest scoring node.
+func (router *Rendezvous) best(key_hash uint64) int {
+	best_index := -1
+	best_score := math.Inf(-1)
+	for index, node_hash := range router.node_hashes {
+		if score := router.score(key_hash, index, node_hash); score > best_score {
+			best_index, best_score = index, score
+		}
+	}
+	return best_index
+}
+
+// score maps the combined hash to (0, 1) and returns -weight/ln(u), whose
+// maximum picks each node with probability proportional to its weight.
+func (router *Rendezvous) score(key_hash uint64, index int, node_hash uint64) float64 {
+	unit := (float64(mix64(key_hash^node_hash)>>11) + 0.5) / (1 << 53)
+	return -router.weights[index] / math.Log(unit)
+}
@@ -0,0 +1,53 @@
+package consistenthash
+
+// PeerRouter maps keys to nodes. Map (the ring of virtual nodes), Rendezvous,
+// Jump and Maglev implement it. Like Map, routers are not safe for
+// concurrent updates; Get may run concurrently with other Gets.
+type PeerRouter interface {
+	Add(nodes ...string)
+	Remove(nodes ...string)
+	Set(nodes []string)
+	Get(key string) string
+}
+
+// WeightedRouter is implemented by routers that can give a node a share of
+// the keyspace proportional to its weight.
+type WeightedRouter interface {
+	AddWeighted(node string, weight int)
+}
+
+// ReplicaRouter is implemented by routers that can name backup owners.
+// GetN returns up to n distinct nodes for key, the primary owner first.
+type ReplicaRouter interface {
+	GetN(key string, n int) []string
+}
+
+// LoadBalancer is implemented by routers that can bound per-node load.
+// SetLocal names the caller's own node, whose load the router cannot see.
+type LoadBalancer interface {
+	SetLoadFactor(factor float64)
+	SetLocal(node string)
+	Inc(node string)
+	Done(node string)
+}
+
+// hash_string is 64-bit FNV-1a.
+func hash_string(value string) uint64 {
+	hash_value := uint64(14695981039346656037)
+	for index := 0; index < len(value); index++ {
+		hash_value ^= uint64(value[index])
+		hash_value *= 1099511628211
+	}
+	return hash_value
+}
+
+// mix64 is the splitmix64 finalizer, used to combine two hashes into one
+// with good avalanche.
+func mix64(value uint64) uint64 {
+	value ^= value >> 30
+	value *= 0xbf58476d1ce4e5b9
+	value ^= value >> 27
+	value *= 0x94d049bb133111eb
+	value ^= value >> 31
+	return value
+}
@@ -0,0 +1,219 @@
+package consistenthash
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// routers lists each PeerRouter with the worst balance it is expected to
+// reach on the shared tests; a ring of 50 crc32 virtual nodes is the least even.
+var routers = []struct {
+	name        string
+	new_router  func() PeerRouter
+	max_balance float64
+}{
+	{"ring", func() PeerRouter { return New(50, nil) }, 1.8},
+	{"rendezvous", func() PeerRouter { return NewRendezvous() }, 1.15},
+	{"jump", func() PeerRouter { return NewJump() }, 1.15},
+	{"maglev", func() PeerRouter { return NewMaglev(0) }, 1.15},
+}
+
+func test_nodes(count int) []string {
+	nodes := make([]string, count)
+	for index := range nodes {
+		nodes[index] = "10.0.0." + strconv.Itoa(index) + ":9000"
+	}
+	return nodes
+}
+
+func test_keys(count int) []string {
+	keys := make([]string, count)
+	for index := range keys {
+		keys[index] = "key" + strconv.Itoa(index)
+	}
+	return keys
+}
+
+// balance returns the largest node share divided by the ideal share.
+func balance(router PeerRouter, nodes []string, keys []string) float64 {
+	counts := make(map[string]int, len(nodes))
+	for _, key := range keys {
+		counts[router.Get(key)]++
+	}
+	largest := 0
+	for _, node := range nodes {
+		largest = max(largest, counts[node])
+	}
+	return float64(largest) * float64(len(nodes)) / float64(len(keys))
+}
+
+// movement returns the fraction of keys whose node changes after change.
+func movement(router PeerRouter, keys []string, change func()) float64 {
+	before := make([]string, len(keys))
+	for index, key := range keys {
+		before[index] = router.Get(key)
+	}
+	change()
+	moved := 0
+	for index, key := range keys {
+		if router.Get(key) != before[index] {
+			moved++
+		}
+	}
+	return float64(moved) / float64(len(keys))
+}
+
+func TestRoutersBalanceAndMovement(t *testing.T) {
+	nodes := test_nodes(10)
+	keys := test_keys(50000)
+	for _, test_router := range routers {
+		t.Run(test_router.name, func(t *testing.T) {
+			router := test_router.new_router()
+			router.Set(nodes)
+			if ratio := balance(router, nodes, keys); ratio > test_router.max_balance {
+				t.Fatalf("expected the busiest node within %.2fx of its share, got %.2f", test_router.max_balance, ratio)
+			}
+
+			added := movement(router, keys, func() { router.Add("10.0.0.10:9000") })
+			if added > 2.0/11 {
+				t.Fatalf("expected about 1/11 of keys to move when adding a node, got %.3f", added)
+			}
+			// Jump only keeps movement minimal when removing the last bucket.
+			removed := movement(router, keys, func() { router.Remove("10.0.0.10:9000") })
+			if removed > 2.0/11 {
+				t.Fatalf("expected about 1/11 of keys to move when removing a node, got %.3f", removed)
+			}
+			for _, key := range keys[:1000] {
+				if router.Get(key) == "10.0.0.10:9000" {
+					t.Fatalf("expected removed node to own no keys")
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedRouters(t *testing.T) {
+	keys := test_keys(50000)
+	for _, test_router := range routers {
+		weighted_router, ok := test_router.new_router().(WeightedRouter)
+		if !ok {
+			continue
+		}
+		t.Run(test_router.name, func(t *testing.T) {
+			weighted_router.AddWeighted("small", 1)
+			weighted_router.AddWeighted("large", 3)
+			counts := make(map[string]int)
+			for _, key := range keys {
+				counts[weighted_router.(PeerRouter).Get(key)]++
+			}
+			if ratio := float64(counts["large"]) / float64(counts["small"]); ratio < 2.1 || ratio > 3.9 {
+				t.Fatalf("expected about 3x the keys on the weight-3 node, got %.2f (%v)", ratio, counts)
+			}
+		})
+	}
+}
+
+func TestEmptyRouters(t *testing.T) {
+	for _, test_router := range routers {
+		router := test_router.new_router()
+		if node := router.Get("key"); node != "" {
+			t.Fatalf("%s: expected no node from an empty router, got %q", test_router.name, node)
+		}
+		router.Set([]string{"only"})
+		router.Remove("only")
+		if node := router.Get("key"); node != "" {
+			t.Fatalf("%s: expected no node after removing the last one, got %q", test_router.name, node)
+		}
+	}
+}
+
+func TestMaglevRoundsTableSizeToPrime(t *testing.T) {
+	for _, table_size := range []int{1, 2, 8, 9, 10} {
+		router := NewMaglev(table_size)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			router.Add("a", "b")
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("table size %d: populating the table hung", table_size)
+		}
+		if len(router.table) != next_prime(table_size) {
+			t.Fatalf("table size %d: expected %d slots, got %d", table_size, next_prime(table_size), len(router.table))
+		}
+		for _, node := range router.table {
+			if node != "a" && node != "b" {
+				t.Fatalf("table size %d: expected every slot filled, got %q", table_size, router.table)
+			}
+		}
+	}
+}
+
+func BenchmarkRouterGet(b *testing.B) {
+	keys := test_keys(1024)
+	for _, test_router := range routers {
+		for _, node_count := range []int{10, 100} {
+			b.Run(test_router.name+"/nodes="+strconv.Itoa(node_count), func(b *testing.B) {
+				router := test_router.new_router()
+				router.Set(test_nodes(node_count))
+				b.ResetTimer()
+				for index := 0; index < b.N; index++ {
+					router.Get(keys[index%len(keys)])
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRouterBalance(b *testing.B) {
+	nodes := test_nodes(20)
+	keys := test_keys(100000)
+	for _, test_router := range routers {
+		b.Run(test_router.name, func(b *testing.B) {
+			router := test_router.new_router()
+			router.Set(nodes)
+			var ratio float64
+			for index := 0; index < b.N; index++ {
+				ratio = balance(router, nodes, keys)
+			}
+			b.ReportMetric(ratio, "max/ideal")
+		})
+	}
+}
+
+func BenchmarkRouterMovement(b *testing.B) {
+	nodes := test_nodes(20)
+	keys := test_keys(100000)
+	for _, test_router := range routers {
+		b.Run(test_router.name, func(b *testing.B) {
+			var moved float64
+			for index := 0; index < b.N; index++ {
+				router := test_router.new_router()
+				router.Set(nodes)
+				moved = movement(router, keys, func() { router.Add("10.0.1.0:9000") })
+			}
+			b.ReportMetric(moved*100, "%moved")
+		})
+	}
+}
+
+func TestReplicaRoutersAgreeWithGet(t *testing.T) {
+	nodes := test_nodes(5)
+	for _, test_router := range routers {
+		router := test_router.new_router()
+		replica_router, ok := router.(ReplicaRouter)
+		if !ok {
+			continue
+		}
+		router.Set(nodes)
+		for _, key := range test_keys(500) {
+			replicas := replica_router.GetN(key, 3)
+			if len(replicas) != 3 || replicas[0] != router.Get(key) || replicas[1] == replicas[0] || replicas[2] == replicas[1] || replicas[2] == replicas[0] {
+				t.Fatalf("%s: expected 3 distinct nodes led by the owner, got %v", test_router.name, replicas)
+			}
+		}
+	}
+}
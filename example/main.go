@@ -22,6 +22,7 @@ func main() {
 		get_key           = flag.String("get", "", "optional key to fetch")
 		cache_megabytes   = flag.Int64("cache-mb", 64, "cache size in MB")
 		expiration_millis = flag.Int64("expire-ms", 0, "default expiration in ms (0 = no expiration)")
+		weight            = flag.Int("weight", 1, "share of the keyspace relative to other nodes")
 	)
 	flag.Parse()
 
@@ -67,16 +68,21 @@ func main() {
 		registry_client := registry.New(client)
 		request_context, cancel := context.WithCancel(context.Background())
 		defer cancel()
-		watch_channel := registry_client.Watch(request_context, *service_name)
+		watch_channel := registry_client.WatchInstances(request_context, *service_name)
 		go func() {
-			for addresses := range watch_channel {
-				picker.SetPeers(addresses...)
-				log.Printf("[etcd] peers updated: %v", addresses)
+			for instances := range watch_channel {
+				weights := make(map[string]int, len(instances))
+				for _, instance := range instances {
+					weights[instance.Address] = instance.Weight
+				}
+				picker.SetWeightedPeers(weights)
+				log.Printf("[etcd] peers updated: %v", weights)
 			}
 		}()
 	}
 
 	server := lru_cache.NewServer(*listen_address, *service_name)
+	server.SetWeight(*weight)
 	if error_value := server.Start(); error_value != nil {
 		log.Fatalf("server start failed: %v", error_value)
 	}
@@ -0,0 +1,120 @@
+package lru_cache
+
+import "time"
+
+// ExpiryOptions tunes active expiration. Zero fields use the defaults.
+type ExpiryOptions struct {
+	Interval    time.Duration // pause between sweeps, 100ms by default
+	Sample_size int           // ttl keys sampled per shard and round, 20 by default
+	Budget      time.Duration // wall time one sweep may use, a quarter of Interval by default
+}
+
+type expiry_sweeper struct {
+	options      ExpiryOptions
+	stop_channel chan struct{}
+	done_channel chan struct{}
+}
+
+// StartExpiry starts a background sweeper that reclaims expired entries
+// without waiting for a Get. Like Redis, it samples keys that have a ttl and
+// keeps sampling a shard while more than a quarter of a sample had expired,
+// until the time budget of the sweep is spent. Calling it again restarts the
+// sweeper with the new options.
+func (cache *Cache) StartExpiry(options ExpiryOptions) {
+	if options.Interval <= 0 {
+		options.Interval = 100 * time.Millisecond
+	}
+	if options.Sample_size <= 0 {
+		options.Sample_size = 20
+	}
+	if options.Budget <= 0 {
+		options.Budget = options.Interval / 4
+	}
+	sweeper := &expiry_sweeper{
+		options:      options,
+		stop_channel: make(chan struct{}),
+		done_channel: make(chan struct{}),
+	}
+
+	cache.sweeper_mutex.Lock()
+	previous := cache.sweeper
+	cache.sweeper = sweeper
+	cache.sweeper_mutex.Unlock()
+	previous.stop()
+	go sweeper.run(cache)
+}
+
+// StopExpiry stops the background sweeper and waits for it to exit.
+func (cache *Cache) StopExpiry() {
+	cache.sweeper_mutex.Lock()
+	sweeper := cache.sweeper
+	cache.sweeper = nil
+	cache.sweeper_mutex.Unlock()
+	sweeper.stop()
+}
+
+func (sweeper *expiry_sweeper) stop() {
+	if sweeper == nil {
+		return
+	}
+	close(sweeper.stop_channel)
+	<-sweeper.done_channel
+}
+
+func (sweeper *expiry_sweeper) run(cache *Cache) {
+	defer close(sweeper.done_channel)
+	ticker := time.NewTicker(sweeper.options.Interval)
+	defer ticker.Stop()
+	next_shard := 0
+	for {
+		select {
+		case <-sweeper.stop_channel:
+			return
+		case <-ticker.C:
+			_, next_shard = cache.sweep(sweeper.options, next_shard)
+		}
+	}
+}
+
+// sweep runs one expiry pass starting at start_shard. It returns the number of
+// entries removed and the shard the next pass should start from, so a pass
+// cut short by the budget does not starve later shards.
+func (cache *Cache) sweep(options ExpiryOptions, start_shard int) (int, int) {
+	started := time.Now()
+	expired_total := 0
+	for visited := 0; visited < len(cache.shards); visited++ {
+		shard_index := (start_shard + visited) % len(cache.shards)
+		shard := cache.shards[shard_index]
+		for {
+			sampled, expired := shard.sweep(cache.now(), options.Sample_size)
+			expired_total += expired
+			if options.Budget > 0 && time.Since(started) >= options.Budget {
+				return expired_total, (shard_index + 1) % len(cache.shards)
+			}
+			if sampled == 0 || expired*4 <= sampled {
+				break
+			}
+		}
+	}
+	return expired_total, start_shard
+}
+
+// sweep checks up to sample_size keys with a ttl, relying on randomized map
+// iteration for the sample, and drops those past their deadline.
+func (shard *cache_shard) sweep(current_time int64, sample_size int) (sampled int, expired int) {
+	shard.mutex.Lock()
+	defer shard.unlock()
+	for key, deadline := range shard.expiring {
+		if sampled == sample_size {
+			break
+		}
+		sampled++
+		if current_time < deadline {
+			continue
+		}
+		if shard.expire(key) {
+			expired++
+		}
+	}
+	return sampled, expired
+}
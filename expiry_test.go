@@ -0,0 +1,64 @@
+package lru_cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCacheSweepReclaimsExpiredEntries(t *testing.T) {
+	clock := &test_clock{current_time: time.Unix(1000, 0)}
+	cache := NewCache(CacheOptions{Max_bytes: 1 << 20, Shard_count: 4, Clock: clock})
+	for index := 0; index < 100; index++ {
+		cache.Set("ttl"+strconv.Itoa(index), ByteView{bytes: []byte("v")}, time.Minute)
+		cache.Set("keep"+strconv.Itoa(index), ByteView{bytes: []byte("v")}, 0)
+	}
+
+	if expired, _ := cache.sweep(ExpiryOptions{Sample_size: 20}, 0); expired != 0 {
+		t.Fatalf("expected nothing to expire yet, got %d", expired)
+	}
+	clock.Advance(2 * time.Minute)
+	if expired, _ := cache.sweep(ExpiryOptions{Sample_size: 20}, 0); expired != 100 {
+		t.Fatalf("expected 100 expired entries, got %d", expired)
+	}
+	if cache.Len() != 100 {
+		t.Fatalf("expected only entries without ttl to remain, got %d", cache.Len())
+	}
+	if evicted, expired := cache.EvictionStats(); evicted != 0 || expired != 100 {
+		t.Fatalf("unexpected eviction stats: evicted=%d expired=%d", evicted, expired)
+	}
+}
+
+func TestCacheEvictionsCountedSeparately(t *testing.T) {
+	cache := NewCache(CacheOptions{Max_bytes: 30})
+	for index := 0; index < 20; index++ {
+		cache.Set("k"+strconv.Itoa(index), ByteView{bytes: []byte("value")}, time.Hour)
+	}
+	cache.Remove("k19")
+	evicted, expired := cache.EvictionStats()
+	if evicted == 0 || expired != 0 {
+		t.Fatalf("unexpected eviction stats: evicted=%d expired=%d", evicted, expired)
+	}
+	if evicted+uint64(cache.Len()) != 19 {
+		t.Fatalf("expected explicit removal not to count as eviction, got %d evicted with %d left", evicted, cache.Len())
+	}
+}
+
+func TestCacheStartStopExpiry(t *testing.T) {
+	clock := &test_clock{current_time: time.Unix(1000, 0)}
+	cache := NewCache(CacheOptions{Max_bytes: 1 << 20, Clock: clock})
+	cache.Set("k1", ByteView{bytes: []byte("v")}, time.Second)
+	cache.StartExpiry(ExpiryOptions{Interval: time.Millisecond})
+	defer cache.StopExpiry()
+
+	clock.Advance(2 * time.Second)
+	wait_for(t, func() bool { return cache.Len() == 0 })
+
+	cache.StopExpiry()
+	cache.Set("k2", ByteView{bytes: []byte("v")}, time.Second)
+	clock.Advance(2 * time.Second)
+	time.Sleep(5 * time.Millisecond)
+	if cache.Len() != 1 {
+		t.Fatalf("expected a stopped sweeper to leave entries alone")
+	}
+}
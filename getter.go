@@ -0,0 +1,69 @@
+package lru_cache
+
+import (
+	"context"
+	"time"
+)
+
+// Getter loads data for a key.
+type Getter interface {
+	Get(key string) ([]byte, error)
+}
+
+// GetterFunc adapts a function to Getter.
+type GetterFunc func(key string) ([]byte, error)
+
+func (getter GetterFunc) Get(key string) ([]byte, error) {
+	return getter(key)
+}
+
+// ContextGetter loads data for a key, honouring cancellation and deadlines
+// carried by the request context.
+type ContextGetter interface {
+	Get(request_context context.Context, key string) ([]byte, error)
+}
+
+// ContextGetterFunc adapts a function to ContextGetter.
+type ContextGetterFunc func(request_context context.Context, key string) ([]byte, error)
+
+func (getter ContextGetterFunc) Get(request_context context.Context, key string) ([]byte, error) {
+	return getter(request_context, key)
+}
+
+// EntryMeta tells the group how to cache a loaded value.
+type EntryMeta struct {
+	TTL     time.Duration // 0 uses the group default expiration
+	NoCache bool          // return the value without caching it
+}
+
+// MetaGetter loads data for a key together with caching metadata, for
+// backends that know how fresh each record is.
+type MetaGetter interface {
+	Get(request_context context.Context, key string) ([]byte, EntryMeta, error)
+}
+
+// MetaGetterFunc adapts a function to MetaGetter.
+type MetaGetterFunc func(request_context context.Context, key string) ([]byte, EntryMeta, error)
+
+func (getter MetaGetterFunc) Get(request_context context.Context, key string) ([]byte, EntryMeta, error) {
+	return getter(request_context, key)
+}
+
+// getter_adapter lets a plain Getter serve as a ContextGetter.
+type getter_adapter struct {
+	getter Getter
+}
+
+func (adapter getter_adapter) Get(_ context.Context, key string) ([]byte, error) {
+	return adapter.getter.Get(key)
+}
+
+// context_getter_adapter lets a ContextGetter serve as a MetaGetter.
+type context_getter_adapter struct {
+	getter ContextGetter
+}
+
+func (adapter context_getter_adapter) Get(request_context context.Context, key string) ([]byte, EntryMeta, error) {
+	bytes, error_value := adapter.getter.Get(request_context, key)
+	return bytes, EntryMeta{}, error_value
+}
@@ -2,38 +2,63 @@ package lru_cache
 
 import (
 	"context"
+	"errors"
+	"math/rand/v2"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"lru_cache/singleflight"
 )
 
-// Getter loads data for a key.
-type Getter interface {
-	Get(key string) ([]byte, error)
-}
-
-// GetterFunc adapts a function to Getter.
-type GetterFunc func(key string) ([]byte, error)
-
-func (getter GetterFunc) Get(key string) ([]byte, error) {
-	return getter(key)
-}
-
 // Group is a cache namespace.
 type Group struct {
 	group_name         string
-	data_getter        Getter
+	data_getter        MetaGetter
 	main_cache         *Cache
+	hot_cache          *Cache
+	hot_cache_bytes    int64
+	hot_expiration     time.Duration
+	hot_fill_rate      float64
 	cache_options      CacheOptions
 	peer_picker        PeerPicker
 	load_group         *singleflight.Group
 	default_expiration time.Duration
+	peer_timeout       time.Duration
+	peer_fallback      PeerFallback
+	broadcast_remove   bool
+	refresh_ahead      float64
+	negative_ttl       time.Duration
+	error_ttl          time.Duration
+	error_classifier   func(error) bool
+	expiry_options     *ExpiryOptions
+	budget             *Budget
+	budget_weight      float64
+	registry           *GroupRegistry
+	refreshing         sync.Map
+	fences             [load_fence_count]atomic.Uint64 // advanced by Remove, see fill_unless_removed
+	stale_count        uint64
+	refresh_count      uint64
+	refresh_errors     uint64
 }
 
-var (
-	groups_mutex sync.RWMutex
-	group_map    = make(map[string]*Group)
+// refresh_timeout bounds a background refresh started by stale-while-revalidate
+// or refresh-ahead.
+const refresh_timeout = 30 * time.Second
+
+// PeerFallback decides when a failed peer fetch falls back to the local getter.
+// ErrNotFound from the owning peer is authoritative and never falls back.
+type PeerFallback int
+
+const (
+	// FallbackAlways loads locally after any other peer error.
+	FallbackAlways PeerFallback = iota
+	// FallbackNever returns peer errors to the caller.
+	FallbackNever
+	// FallbackOnTransportError loads locally only when the peer could not be
+	// reached; errors reported by the peer are returned.
+	FallbackOnTransportError
 )
 
 // GroupOption configures Group.
@@ -49,6 +74,98 @@ func WithStoreType(store_type string) GroupOption {
 	return func(group *Group) { group.cache_options.Store_type = store_type }
 }
 
+// WithShards splits the local cache into shard_count independently locked shards.
+func WithShards(shard_count int) GroupOption {
+	return func(group *Group) { group.cache_options.Shard_count = shard_count }
+}
+
+// WithPeerTimeout bounds each peer RPC (2 seconds by default). Values of 0
+// or less keep the default, since an expired deadline would fail every call.
+func WithPeerTimeout(timeout time.Duration) GroupOption {
+	return func(group *Group) {
+		if timeout > 0 {
+			group.peer_timeout = timeout
+		}
+	}
+}
+
+// WithPeerFallback sets when a failed peer fetch is retried through the getter.
+func WithPeerFallback(fallback PeerFallback) GroupOption {
+	return func(group *Group) { group.peer_fallback = fallback }
+}
+
+// WithRemoveBroadcast makes Remove invalidate the key on every peer, not just
+// the owner, so hot copies held elsewhere are dropped too.
+func WithRemoveBroadcast(enabled bool) GroupOption {
+	return func(group *Group) { group.broadcast_remove = enabled }
+}
+
+// WithHotCache keeps a separate cache of at most max_bytes for values fetched
+// from peers, so hot keys owned elsewhere skip the network. Entries expire
+// after ttl (0 means no expiration).
+func WithHotCache(max_bytes int64, ttl time.Duration) GroupOption {
+	return func(group *Group) {
+		group.hot_cache_bytes = max_bytes
+		group.hot_expiration = ttl
+	}
+}
+
+// WithHotCacheFillRate sets the probability that a peer-fetched value is
+// copied into the hot cache (0.1 by default).
+func WithHotCacheFillRate(fill_rate float64) GroupOption {
+	return func(group *Group) { group.hot_fill_rate = fill_rate }
+}
+
+// WithStaleWhileRevalidate keeps serving an expired value for up to grace
+// while a single background load refreshes it.
+func WithStaleWhileRevalidate(grace time.Duration) GroupOption {
+	return func(group *Group) { group.cache_options.Stale_grace = grace }
+}
+
+// WithRefreshAhead reloads a value in the background when it is read within
+// the last fraction of its TTL (for example 0.1 for the last 10%).
+func WithRefreshAhead(fraction float64) GroupOption {
+	return func(group *Group) { group.refresh_ahead = fraction }
+}
+
+// WithNegativeCache remembers ErrNotFound results for ttl, so repeated
+// lookups of missing keys stop reaching the getter.
+func WithNegativeCache(ttl time.Duration) GroupOption {
+	return func(group *Group) { group.negative_ttl = ttl }
+}
+
+// WithErrorCache remembers other getter errors for ttl when classify returns
+// true for them. Keep ttl short; context errors are never cached.
+func WithErrorCache(ttl time.Duration, classify func(error) bool) GroupOption {
+	return func(group *Group) {
+		group.error_ttl = ttl
+		group.error_classifier = classify
+	}
+}
+
+// WithActiveExpiry reclaims expired entries in the background instead of only
+// when they are read. Stop it with Close.
+func WithActiveExpiry(options ExpiryOptions) GroupOption {
+	return func(group *Group) { group.expiry_options = &options }
+}
+
+// OnEvict registers a listener for values leaving the main cache, with the
+// reason they left. It runs outside the cache locks.
+func OnEvict(listener func(key string, value ByteView, reason EvictReason)) GroupOption {
+	return func(group *Group) { group.cache_options.On_evict = listener }
+}
+
+// WithOverheadAccounting makes the cache limit include the estimated
+// per-entry bookkeeping bytes, which dominate when values are small.
+func WithOverheadAccounting() GroupOption {
+	return func(group *Group) { group.cache_options.Account_overhead = true }
+}
+
+// WithClock replaces the clock used for expiry, mainly for tests.
+func WithClock(clock Clock) GroupOption {
+	return func(group *Group) { group.cache_options.Clock = clock }
+}
+
 // WithPeers registers a peer picker for distributed cache.
 func WithPeers(peer_picker PeerPicker) GroupOption {
 	return func(group *Group) { group.peer_picker = peer_picker }
@@ -56,6 +173,34 @@ func WithPeers(peer_picker PeerPicker) GroupOption {
 
 // NewGroup creates a new cache group.
 func NewGroup(group_name string, cache_bytes int64, data_getter Getter, options ...GroupOption) *Group {
+	if data_getter == nil {
+		panic("nil Getter")
+	}
+	return NewGroupContext(group_name, cache_bytes, getter_adapter{getter: data_getter}, options...)
+}
+
+// NewGroupContext creates a new cache group backed by a context-aware getter.
+func NewGroupContext(group_name string, cache_bytes int64, data_getter ContextGetter, options ...GroupOption) *Group {
+	if data_getter == nil {
+		panic("nil Getter")
+	}
+	return NewGroupMeta(group_name, cache_bytes, context_getter_adapter{getter: data_getter}, options...)
+}
+
+// NewGroupMeta creates a new cache group whose getter also decides the TTL of
+// each value, or that it must not be cached at all.
+func NewGroupMeta(group_name string, cache_bytes int64, data_getter MetaGetter, options ...GroupOption) *Group {
+	group, error_value := TryNewGroupMeta(group_name, cache_bytes, data_getter, options...)
+	if error_value != nil {
+		panic(error_value)
+	}
+	return group
+}
+
+// TryNewGroupMeta is like NewGroupMeta but returns ErrGroupExists instead of
+// panicking when the registry keeps an existing group of the same name.
+// Other getters can be wrapped in a MetaGetterFunc.
+func TryNewGroupMeta(group_name string, cache_bytes int64, data_getter MetaGetter, options ...GroupOption) (*Group, error) {
 	if data_getter == nil {
 		panic("nil Getter")
 	}
@@ -64,6 +209,9 @@ func NewGroup(group_name string, cache_bytes int64, data_getter Getter, options
 		data_getter:   data_getter,
 		cache_options: CacheOptions{Max_bytes: cache_bytes},
 		load_group:    &singleflight.Group{},
+		peer_timeout:  2 * time.Second,
+		hot_fill_rate: 0.1,
+		registry:      default_registry,
 	}
 	for _, option := range options {
 		option(group)
@@ -71,18 +219,94 @@ func NewGroup(group_name string, cache_bytes int64, data_getter Getter, options
 	if group.main_cache == nil {
 		group.main_cache = NewCache(group.cache_options)
 	}
-	groups_mutex.Lock()
-	group_map[group_name] = group
-	groups_mutex.Unlock()
-	return group
+	if group.hot_cache == nil && group.hot_cache_bytes > 0 {
+		hot_options := group.cache_options
+		hot_options.Max_bytes = group.hot_cache_bytes
+		hot_options.Stale_grace = 0
+		hot_options.On_evict = nil
+		group.hot_cache = NewCache(hot_options)
+	}
+	if group.expiry_options != nil {
+		group.main_cache.StartExpiry(*group.expiry_options)
+		if group.hot_cache != nil {
+			group.hot_cache.StartExpiry(*group.expiry_options)
+		}
+	}
+	if group.budget != nil {
+		group.budget.register(group, group.budget_weight)
+	}
+	if group.registry != nil {
+		if error_value := group.registry.Add(group); error_value != nil {
+			group.Close()
+			return nil, error_value
+		}
+	}
+	return group, nil
 }
 
-// GetGroup retrieves a group by name.
-func GetGroup(group_name string) *Group {
-	groups_mutex.RLock()
-	group := group_map[group_name]
-	groups_mutex.RUnlock()
-	return group
+// GroupStats is a snapshot of a group's cache statistics. Hot fields describe
+// the cache of peer-owned values and stay zero when it is disabled. A lookup
+// answered by the hot cache counts only in HotHits; one that misses both
+// caches counts in Misses and HotMisses.
+type GroupStats struct {
+	Name          string
+	Hits          uint64
+	Misses        uint64
+	Bytes         int64
+	Entries       int
+	HotHits       uint64
+	HotMisses     uint64
+	HotBytes      int64
+	HotEntries    int
+	Evictions     uint64 // entries dropped to stay within the byte budget
+	Expirations   uint64 // entries dropped because their ttl passed
+	NegativeHits  uint64 // lookups answered by a cached not-found or error
+	StaleServed   uint64 // expired values served during the grace window
+	Refreshes     uint64 // background reloads started
+	RefreshErrors uint64 // background reloads that failed
+}
+
+// Stats returns the group's cache statistics.
+func (group *Group) Stats() GroupStats {
+	hits, misses := group.main_cache.Stats()
+	stats := GroupStats{
+		Name:          group.group_name,
+		Hits:          hits,
+		Misses:        misses,
+		Bytes:         group.main_cache.Bytes(),
+		Entries:       group.main_cache.Len(),
+		NegativeHits:  group.main_cache.NegativeHits(),
+		StaleServed:   atomic.LoadUint64(&group.stale_count),
+		Refreshes:     atomic.LoadUint64(&group.refresh_count),
+		RefreshErrors: atomic.LoadUint64(&group.refresh_errors),
+	}
+	stats.Evictions, stats.Expirations = group.main_cache.EvictionStats()
+	if group.hot_cache != nil {
+		stats.HotHits, stats.HotMisses = group.hot_cache.Stats()
+		stats.HotBytes = group.hot_cache.Bytes()
+		stats.HotEntries = group.hot_cache.Len()
+		stats.NegativeHits += group.hot_cache.NegativeHits()
+	}
+	return stats
+}
+
+// SetCacheBytes changes the main cache budget without a restart. The hot
+// cache keeps the size given to WithHotCache.
+func (group *Group) SetCacheBytes(cache_bytes int64) error {
+	return group.main_cache.SetMaxBytes(cache_bytes)
+}
+
+// MemoryUsage estimates the memory held by the main and hot caches.
+func (group *Group) MemoryUsage() MemoryUsage {
+	usage := group.main_cache.MemoryUsage()
+	if group.hot_cache != nil {
+		hot_usage := group.hot_cache.MemoryUsage()
+		usage.Entries += hot_usage.Entries
+		usage.PayloadBytes += hot_usage.PayloadBytes
+		usage.OverheadBytes += hot_usage.OverheadBytes
+		usage.TotalBytes += hot_usage.TotalBytes
+	}
+	return usage
 }
 
 // Name returns the group name.
@@ -90,6 +314,17 @@ func (group *Group) Name() string {
 	return group.group_name
 }
 
+// Close stops the group's background workers and leaves its budget.
+func (group *Group) Close() {
+	if group.budget != nil {
+		group.budget.unregister(group)
+	}
+	group.main_cache.StopExpiry()
+	if group.hot_cache != nil {
+		group.hot_cache.StopExpiry()
+	}
+}
+
 // RegisterPeers sets the peer picker.
 func (group *Group) RegisterPeers(peer_picker PeerPicker) {
 	group.peer_picker = peer_picker
@@ -97,57 +332,528 @@ func (group *Group) RegisterPeers(peer_picker PeerPicker) {
 
 // Get retrieves a value for a key.
 func (group *Group) Get(key string) (ByteView, error) {
+	return group.GetContext(context.Background(), key)
+}
+
+// GetContext retrieves a value for a key. The context is passed to the peer
+// RPC and the getter, so cancelling it abandons a slow load.
+func (group *Group) GetContext(request_context context.Context, key string) (ByteView, error) {
+	value, _, error_value := group.get_with_meta(request_context, key, false)
+	return value, error_value
+}
+
+// get_with_meta also reports how long the value stays fresh, so peers can
+// pass the remaining TTL on to the node that asked for it. A local lookup
+// loads a miss through the getter instead of asking the owning peer.
+func (group *Group) get_with_meta(request_context context.Context, key string, local bool) (ByteView, EntryMeta, error) {
+	loaded, error_value := group.get(request_context, key, local)
+	return loaded.value, loaded.meta, error_value
+}
+
+// get is get_with_meta returning the version of the cached value as well, or
+// 0 when the value was not cached.
+func (group *Group) get(request_context context.Context, key string, local bool) (loaded_value, error) {
 	if key == "" {
-		return ByteView{}, ErrEmptyKey
+		return loaded_value{}, ErrEmptyKey
 	}
-	if value, ok := group.main_cache.Get(key); ok {
-		return value, nil
+	if value, state, ok := group.lookup_cache(key); ok {
+		if state.negative != nil {
+			return loaded_value{meta: EntryMeta{TTL: state.ttl}}, state.negative
+		}
+		return loaded_value{value: value, meta: group.serve_cached(key, state), version: state.version}, nil
 	}
-	return group.load(key)
+	if local {
+		return group.load_locally(request_context, key)
+	}
+	return group.load(request_context, key)
+}
+
+// GetResult is the outcome of one key in a batch lookup.
+type GetResult struct {
+	Key   string
+	Value ByteView
+	Meta  EntryMeta // remaining TTL and caching hints reported by the source
+	Err   error
+}
+
+// GetMany retrieves several keys at once. Local hits are served directly,
+// misses owned by a peer are sent to it in one batched call per peer, and the
+// rest are loaded through the getter once per distinct key. At most
+// get_many_workers batches and loads run at once. Results follow the order
+// of keys.
+func (group *Group) GetMany(request_context context.Context, keys []string) []GetResult {
+	return group.get_many(request_context, keys, false)
+}
+
+// get_many loads every miss through the getter when local is set, as
+// get_with_meta does.
+func (group *Group) get_many(request_context context.Context, keys []string, local bool) []GetResult {
+	results := make([]GetResult, len(keys))
+	var batches []*peer_batch
+	load_indexes := make(map[string][]int)
+	var load_keys []string
+	for index, key := range keys {
+		results[index].Key = key
+		if key == "" {
+			results[index].Err = ErrEmptyKey
+			continue
+		}
+		if value, state, ok := group.lookup_cache(key); ok {
+			if state.negative != nil {
+				results[index].Meta.TTL = state.ttl
+				results[index].Err = state.negative
+				continue
+			}
+			results[index].Value = value
+			results[index].Meta = group.serve_cached(key, state)
+			continue
+		}
+		if group.peer_picker != nil && !local {
+			if peer_getter, ok := group.peer_picker.PickPeer(key); ok {
+				if multi_getter, ok := peer_getter.(PeerMultiGetter); ok {
+					batches = add_to_batch(batches, multi_getter, index)
+					continue
+				}
+			}
+		}
+		if _, ok := load_indexes[key]; !ok {
+			load_keys = append(load_keys, key)
+		}
+		load_indexes[key] = append(load_indexes[key], index)
+	}
+
+	load := group.load
+	if local {
+		load = group.load_locally
+	}
+	var fallback_mutex sync.Mutex
+	fallback_indexes := make(map[string][]int)
+	var fallback_keys []string
+	workers := new_worker_limit(get_many_workers)
+	for _, batch := range batches {
+		workers.run(func() {
+			indexes := group.get_many_from_peer(request_context, batch.multi_getter, keys, batch.indexes, results)
+			fallback_mutex.Lock()
+			for _, index := range indexes {
+				if _, ok := fallback_indexes[keys[index]]; !ok {
+					fallback_keys = append(fallback_keys, keys[index])
+				}
+				fallback_indexes[keys[index]] = append(fallback_indexes[keys[index]], index)
+			}
+			fallback_mutex.Unlock()
+		})
+	}
+	for _, key := range load_keys {
+		workers.run(func() { group.load_into(request_context, load, key, load_indexes[key], results) })
+	}
+	workers.wait()
+	for _, key := range fallback_keys {
+		workers.run(func() { group.load_into(request_context, group.load_locally, key, fallback_indexes[key], results) })
+	}
+	workers.wait()
+	return results
+}
+
+// get_many_workers bounds how many peer batches and key loads one GetMany
+// runs at once.
+const get_many_workers = 8
+
+// peer_batch collects the indexes of the keys GetMany sends to one peer.
+type peer_batch struct {
+	multi_getter PeerMultiGetter
+	indexes      []int
+}
+
+// add_to_batch appends index to multi_getter's batch. Peers are matched by
+// value only when comparable; others, such as func types, get a batch each.
+func add_to_batch(batches []*peer_batch, multi_getter PeerMultiGetter, index int) []*peer_batch {
+	if reflect.ValueOf(multi_getter).Comparable() {
+		for _, batch := range batches {
+			if reflect.ValueOf(batch.multi_getter).Comparable() && batch.multi_getter == multi_getter {
+				batch.indexes = append(batch.indexes, index)
+				return batches
+			}
+		}
+	}
+	return append(batches, &peer_batch{multi_getter: multi_getter, indexes: []int{index}})
+}
+
+// load_into loads key once and copies the outcome to every index asking for it.
+func (group *Group) load_into(request_context context.Context, load func(context.Context, string) (loaded_value, error), key string, indexes []int, results []GetResult) {
+	loaded, error_value := load(request_context, key)
+	for _, index := range indexes {
+		results[index].Value, results[index].Meta, results[index].Err = loaded.value, loaded.meta, error_value
+	}
+}
+
+// worker_limit runs functions on at most limit goroutines at a time.
+type worker_limit struct {
+	slots      chan struct{}
+	wait_group sync.WaitGroup
+}
+
+func new_worker_limit(limit int) *worker_limit {
+	return &worker_limit{slots: make(chan struct{}, limit)}
+}
+
+// run blocks until a slot is free, then starts fn on its own goroutine.
+func (workers *worker_limit) run(fn func()) {
+	workers.slots <- struct{}{}
+	workers.wait_group.Add(1)
+	go func() {
+		defer func() {
+			<-workers.slots
+			workers.wait_group.Done()
+		}()
+		fn()
+	}()
+}
+
+func (workers *worker_limit) wait() {
+	workers.wait_group.Wait()
+}
+
+// get_many_from_peer fetches the keys at indexes from one peer and returns
+// the indexes the fallback policy sends to the getter instead.
+func (group *Group) get_many_from_peer(request_context context.Context, multi_getter PeerMultiGetter, keys []string, indexes []int, results []GetResult) []int {
+	unique_keys := make([]string, 0, len(indexes))
+	generations := make(map[string]uint64, len(indexes))
+	for _, index := range indexes {
+		if _, ok := generations[keys[index]]; !ok {
+			generations[keys[index]] = group.fence(keys[index]).Load()
+			unique_keys = append(unique_keys, keys[index])
+		}
+	}
+
+	peer_context, cancel := context.WithTimeout(request_context, group.peer_timeout)
+	peer_results, error_value := multi_getter.MultiGet(peer_context, group.group_name, unique_keys)
+	cancel()
+	result_map := make(map[string]GetResult, len(peer_results))
+	for _, result := range peer_results {
+		result_map[result.Key] = result
+	}
+
+	var fallback_indexes []int
+	for _, index := range indexes {
+		result, ok := result_map[keys[index]]
+		switch {
+		case error_value != nil:
+			result.Err = error_value
+		case !ok:
+			result.Err = &RemoteError{Message: "lru_cache: key missing from peer response"}
+		}
+		if result.Err == nil {
+			results[index].Value, results[index].Meta = result.Value, result.Meta
+			group.fill_unless_removed(keys[index], generations[keys[index]], func() {
+				group.populate_hot_cache(keys[index], result.Value, result.Meta)
+			})
+			continue
+		}
+		if !group.should_fall_back(result.Err) {
+			results[index].Meta, results[index].Err = result.Meta, result.Err
+			group.fill_unless_removed(keys[index], generations[keys[index]], func() {
+				group.populate_hot_negative(keys[index], result.Err, result.Meta)
+			})
+			continue
+		}
+		fallback_indexes = append(fallback_indexes, index)
+	}
+	return fallback_indexes
 }
 
 // Set manually populates the cache.
 func (group *Group) Set(key string, value []byte) {
-	group.main_cache.Set(key, ByteView{bytes: clone_bytes(value)}, group.default_expiration)
+	group.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL populates the cache with a per-entry ttl (0 uses the group default).
+func (group *Group) SetWithTTL(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = group.default_expiration
+	}
+	group.main_cache.Set(key, ByteView{bytes: clone_bytes(value)}, ttl)
+}
+
+// Remove deletes a key locally and on the peer that owns it, plus every other
+// peer when broadcasting is enabled.
+func (group *Group) Remove(request_context context.Context, key string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	group.remove_locally(key)
+	if group.peer_picker == nil {
+		return nil
+	}
+
+	// A broadcast goes to the listed peers alone, since they include the
+	// owner; peers need not be comparable, so the owner is not deduplicated.
+	var peers []PeerGetter
+	if peer_lister, ok := group.peer_picker.(PeerLister); ok && group.broadcast_remove {
+		peers = peer_lister.ListPeers()
+	} else if peer_getter, ok := group.peer_picker.PickPeer(key); ok {
+		peers = append(peers, peer_getter)
+	}
+
+	error_values := make([]error, len(peers))
+	var wait_group sync.WaitGroup
+	for index, peer_getter := range peers {
+		wait_group.Add(1)
+		go func() {
+			defer wait_group.Done()
+			error_values[index] = group.remove_from_peer(request_context, peer_getter, key)
+		}()
+	}
+	wait_group.Wait()
+	return errors.Join(error_values...)
+}
+
+// remove_locally also forgets a load in flight, so later callers fetch a
+// fresh value instead of joining one that may predate the removal, and
+// advances the key's fence so that load cannot cache its result afterwards.
+func (group *Group) remove_locally(key string) {
+	group.fence(key).Add(1)
+	group.load_group.Forget(key)
+	group.main_cache.Remove(key)
+	if group.hot_cache != nil {
+		group.hot_cache.Remove(key)
+	}
+}
+
+func (group *Group) remove_from_peer(request_context context.Context, peer_getter PeerGetter, key string) error {
+	peer_remover, ok := peer_getter.(PeerRemover)
+	if !ok {
+		return ErrPeerUnsupported
+	}
+	request_context, cancel := context.WithTimeout(request_context, group.peer_timeout)
+	defer cancel()
+	return peer_remover.Delete(request_context, group.group_name, key)
 }
 
-func (group *Group) load(key string) (ByteView, error) {
-	value_interface, error_value, _ := group.load_group.Do(key, func() (interface{}, error) {
+// lookup_cache checks the main cache, then the hot cache. A hit counts only
+// in the cache that answered; a miss counts in both.
+func (group *Group) lookup_cache(key string) (ByteView, entry_state, bool) {
+	value, state, ok := group.main_cache.peek_entry(key)
+	if ok || group.hot_cache == nil {
+		group.main_cache.record(key, state, ok)
+		return value, state, ok
+	}
+	value, state, ok = group.hot_cache.peek_entry(key)
+	state.hot = true
+	if !ok {
+		group.main_cache.record(key, state, false)
+	}
+	group.hot_cache.record(key, state, ok)
+	return value, state, ok
+}
+
+// serve_cached starts a background refresh for stale values and for values
+// inside the refresh-ahead window, and returns the metadata to report.
+// Stale values are marked NoCache so peers do not copy them. Hot copies of
+// peer-owned values are never refreshed here; they expire and are fetched
+// from the owner again.
+func (group *Group) serve_cached(key string, state entry_state) EntryMeta {
+	if state.stale {
+		atomic.AddUint64(&group.stale_count, 1)
+		group.refresh_in_background(key)
+		return EntryMeta{NoCache: true}
+	}
+	if !state.hot && group.refresh_ahead > 0 && state.lifetime > 0 &&
+		float64(state.ttl) < group.refresh_ahead*float64(state.lifetime) {
+		group.refresh_in_background(key)
+	}
+	return EntryMeta{TTL: state.ttl}
+}
+
+// refresh_in_background reloads key through the getter, giving up after
+// refresh_timeout so a hung backend cannot pin the refresh slot forever.
+func (group *Group) refresh_in_background(key string) {
+	if _, running := group.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	atomic.AddUint64(&group.refresh_count, 1)
+	go func() {
+		defer group.refreshing.Delete(key)
+		refresh_context, cancel := context.WithTimeout(context.Background(), refresh_timeout)
+		defer cancel()
+		if _, error_value := group.load_locally(refresh_context, key); error_value != nil {
+			atomic.AddUint64(&group.refresh_errors, 1)
+		}
+	}()
+}
+
+// loaded_value carries a load result and its metadata through singleflight.
+type loaded_value struct {
+	value   ByteView
+	meta    EntryMeta
+	version uint64 // version the value was cached under, 0 if it was not
+}
+
+func (group *Group) load(request_context context.Context, key string) (loaded_value, error) {
+	return group.do_load(request_context, key, func(request_context context.Context) (loaded_value, error) {
 		if group.peer_picker != nil {
 			if peer_getter, ok := group.peer_picker.PickPeer(key); ok {
-				if value, error_value := group.get_from_peer(peer_getter, key); error_value == nil {
-					return value, nil
+				generation := group.fence(key).Load()
+				value, meta, error_value := group.get_from_peer(request_context, peer_getter, key)
+				if error_value == nil {
+					var version uint64
+					group.fill_unless_removed(key, generation, func() { version = group.populate_hot_cache(key, value, meta) })
+					return loaded_value{value: value, meta: meta, version: version}, nil
+				}
+				if !group.should_fall_back(error_value) {
+					group.fill_unless_removed(key, generation, func() { group.populate_hot_negative(key, error_value, meta) })
+					return loaded_value{meta: meta}, error_value
 				}
 			}
 		}
-		return group.get_locally(key)
+		return group.get_locally(request_context, key)
 	})
-	if error_value != nil {
-		return ByteView{}, error_value
+}
+
+func (group *Group) load_locally(request_context context.Context, key string) (loaded_value, error) {
+	return group.do_load(request_context, key, func(request_context context.Context) (loaded_value, error) {
+		return group.get_locally(request_context, key)
+	})
+}
+
+// do_load shares one load between concurrent callers. A caller whose context
+// ends stops waiting, and the load itself is cancelled once no caller is left.
+func (group *Group) do_load(request_context context.Context, key string, fn func(context.Context) (loaded_value, error)) (loaded_value, error) {
+	if error_value := request_context.Err(); error_value != nil {
+		return loaded_value{}, error_value
 	}
-	return value_interface.(ByteView), nil
+	value_interface, error_value, _ := group.load_group.DoContext(request_context, key, func(request_context context.Context) (interface{}, error) {
+		return fn(request_context)
+	})
+	loaded, _ := value_interface.(loaded_value)
+	return loaded, error_value
 }
 
-func (group *Group) get_locally(key string) (ByteView, error) {
-	bytes, error_value := group.data_getter.Get(key)
+func (group *Group) get_locally(request_context context.Context, key string) (loaded_value, error) {
+	generation := group.fence(key).Load()
+	bytes, meta, error_value := group.data_getter.Get(request_context, key)
 	if error_value != nil {
-		return ByteView{}, error_value
+		if ttl := group.negative_ttl_for(error_value); ttl > 0 {
+			group.fill_unless_removed(key, generation, func() { group.main_cache.set_negative(key, error_value, ttl) })
+			return loaded_value{meta: EntryMeta{TTL: ttl}}, error_value
+		}
+		return loaded_value{}, error_value
+	}
+	if meta.TTL <= 0 {
+		meta.TTL = group.default_expiration
 	}
 	value := ByteView{bytes: clone_bytes(bytes)}
-	group.populate_cache(key, value)
-	return value, nil
+	var version uint64
+	group.fill_unless_removed(key, generation, func() { version = group.populate_cache(key, value, meta) })
+	return loaded_value{value: value, meta: meta, version: version}, nil
 }
 
-func (group *Group) populate_cache(key string, value ByteView) {
-	group.main_cache.Set(key, value, group.default_expiration)
+// load_fence_count is the number of removal counters keys are spread over.
+// Keys sharing a counter only cost each other a skipped cache fill.
+const load_fence_count = 64
+
+// fence returns the counter Remove advances for key.
+func (group *Group) fence(key string) *atomic.Uint64 {
+	hash_value := uint32(2166136261)
+	for index := 0; index < len(key); index++ {
+		hash_value ^= uint32(key[index])
+		hash_value *= 16777619
+	}
+	return &group.fences[hash_value%load_fence_count]
 }
 
-func (group *Group) get_from_peer(peer_getter PeerGetter, key string) (ByteView, error) {
-	request_context, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+// fill_unless_removed caches a load result unless key was removed since the
+// load read generation. A Remove racing with the fill is caught by the second
+// check, which drops the value again.
+func (group *Group) fill_unless_removed(key string, generation uint64, fill func()) {
+	fence := group.fence(key)
+	if fence.Load() != generation {
+		return
+	}
+	fill()
+	if fence.Load() != generation {
+		group.main_cache.Remove(key)
+		if group.hot_cache != nil {
+			group.hot_cache.Remove(key)
+		}
+	}
+}
+
+// populate_cache returns the version the value was cached under, or 0.
+func (group *Group) populate_cache(key string, value ByteView, meta EntryMeta) uint64 {
+	if meta.NoCache {
+		return 0
+	}
+	return group.main_cache.set_versioned(key, value, meta.TTL)
+}
+
+// populate_hot_cache copies a peer-owned value locally. The copy never
+// outlives the owner's remaining TTL. It returns the copy's version, or 0
+// when no copy was made.
+func (group *Group) populate_hot_cache(key string, value ByteView, meta EntryMeta) uint64 {
+	if group.hot_cache == nil || meta.NoCache || rand.Float64() >= group.hot_fill_rate {
+		return 0
+	}
+	ttl := group.hot_expiration
+	if meta.TTL > 0 && (ttl <= 0 || meta.TTL < ttl) {
+		ttl = meta.TTL
+	}
+	return group.hot_cache.set_versioned(key, value, ttl)
+}
+
+// populate_hot_negative copies a tombstone reported by the owning peer, for
+// no longer than the owner keeps it.
+func (group *Group) populate_hot_negative(key string, error_value error, meta EntryMeta) {
+	if group.hot_cache == nil || group.negative_ttl <= 0 || meta.TTL <= 0 || !errors.Is(error_value, ErrNotFound) {
+		return
+	}
+	ttl := min(meta.TTL, group.negative_ttl)
+	if group.hot_expiration > 0 {
+		ttl = min(ttl, group.hot_expiration)
+	}
+	group.hot_cache.set_negative(key, ErrNotFound, ttl)
+}
+
+func (group *Group) negative_ttl_for(error_value error) time.Duration {
+	switch {
+	case errors.Is(error_value, context.Canceled), errors.Is(error_value, context.DeadlineExceeded):
+		return 0
+	case errors.Is(error_value, ErrNotFound):
+		return group.negative_ttl
+	case group.error_classifier != nil && group.error_classifier(error_value):
+		return group.error_ttl
+	default:
+		return 0
+	}
+}
+
+func (group *Group) get_from_peer(request_context context.Context, peer_getter PeerGetter, key string) (ByteView, EntryMeta, error) {
+	request_context, cancel := context.WithTimeout(request_context, group.peer_timeout)
 	defer cancel()
+	if meta_getter, ok := peer_getter.(PeerMetaGetter); ok {
+		bytes, meta, error_value := meta_getter.GetWithMeta(request_context, group.group_name, key)
+		if error_value != nil {
+			return ByteView{}, meta, error_value
+		}
+		return ByteView{bytes: bytes}, meta, nil
+	}
 	bytes, error_value := peer_getter.Get(request_context, group.group_name, key)
 	if error_value != nil {
-		return ByteView{}, error_value
+		return ByteView{}, EntryMeta{}, error_value
+	}
+	return ByteView{bytes: bytes}, EntryMeta{}, nil
+}
+
+func (group *Group) should_fall_back(error_value error) bool {
+	if errors.Is(error_value, ErrNotFound) {
+		return false
+	}
+	switch group.peer_fallback {
+	case FallbackNever:
+		return false
+	case FallbackOnTransportError:
+		var remote_error *RemoteError
+		return !errors.As(error_value, &remote_error)
+	default:
+		return true
 	}
-	return ByteView{bytes: bytes}, nil
 }
@@ -0,0 +1,133 @@
+package lru_cache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DuplicatePolicy decides what a registry does when a group name is reused.
+type DuplicatePolicy int
+
+const (
+	// DuplicateReplace closes the existing group and registers the new one.
+	DuplicateReplace DuplicatePolicy = iota
+	// DuplicateError keeps the existing group and reports ErrGroupExists.
+	// NewGroup panics with it, like it does for a nil Getter; TryNewGroupMeta
+	// returns it.
+	DuplicateError
+)
+
+// GroupRegistry is a namespace of groups. The package functions GetGroup,
+// ListGroups and DestroyGroup use a default registry; tests and embedded
+// servers can keep isolated namespaces with their own.
+type GroupRegistry struct {
+	mutex     sync.RWMutex
+	groups    map[string]*Group
+	duplicate DuplicatePolicy
+}
+
+var default_registry = NewGroupRegistry(DuplicateReplace)
+
+// NewGroupRegistry creates an empty registry.
+func NewGroupRegistry(duplicate DuplicatePolicy) *GroupRegistry {
+	return &GroupRegistry{groups: make(map[string]*Group), duplicate: duplicate}
+}
+
+// DefaultRegistry returns the registry used by NewGroup unless WithRegistry is given.
+func DefaultRegistry() *GroupRegistry {
+	return default_registry
+}
+
+// WithRegistry registers the group in registry instead of the default one.
+// A nil registry leaves the group unregistered, so it can be added later with
+// GroupRegistry.Add.
+func WithRegistry(registry *GroupRegistry) GroupOption {
+	return func(group *Group) { group.registry = registry }
+}
+
+// SetDuplicatePolicy changes how later registrations treat a name in use.
+func (registry *GroupRegistry) SetDuplicatePolicy(duplicate DuplicatePolicy) {
+	registry.mutex.Lock()
+	registry.duplicate = duplicate
+	registry.mutex.Unlock()
+}
+
+// Add registers group under its name.
+func (registry *GroupRegistry) Add(group *Group) error {
+	registry.mutex.Lock()
+	previous := registry.groups[group.group_name]
+	if previous != nil && previous != group && registry.duplicate == DuplicateError {
+		registry.mutex.Unlock()
+		return fmt.Errorf("%w: %s", ErrGroupExists, group.group_name)
+	}
+	registry.groups[group.group_name] = group
+	registry.mutex.Unlock()
+	if previous != nil && previous != group {
+		previous.Close()
+	}
+	return nil
+}
+
+// Get returns the group registered under group_name, or nil.
+func (registry *GroupRegistry) Get(group_name string) *Group {
+	registry.mutex.RLock()
+	group := registry.groups[group_name]
+	registry.mutex.RUnlock()
+	return group
+}
+
+// List returns the registered group names in order.
+func (registry *GroupRegistry) List() []string {
+	groups := registry.list()
+	names := make([]string, len(groups))
+	for index, group := range groups {
+		names[index] = group.group_name
+	}
+	return names
+}
+
+// Destroy unregisters a group, stops its background workers and drops its
+// cached values. It reports whether the group existed.
+func (registry *GroupRegistry) Destroy(group_name string) bool {
+	registry.mutex.Lock()
+	group := registry.groups[group_name]
+	delete(registry.groups, group_name)
+	registry.mutex.Unlock()
+	if group == nil {
+		return false
+	}
+	group.Close()
+	group.main_cache.Clear()
+	if group.hot_cache != nil {
+		group.hot_cache.Clear()
+	}
+	return true
+}
+
+func (registry *GroupRegistry) list() []*Group {
+	registry.mutex.RLock()
+	groups := make([]*Group, 0, len(registry.groups))
+	for _, group := range registry.groups {
+		groups = append(groups, group)
+	}
+	registry.mutex.RUnlock()
+	sort.Slice(groups, func(i, j int) bool { return groups[i].group_name < groups[j].group_name })
+	return groups
+}
+
+// GetGroup retrieves a group by name from the default registry.
+func GetGroup(group_name string) *Group {
+	return default_registry.Get(group_name)
+}
+
+// ListGroups returns the names of the groups in the default registry.
+func ListGroups() []string {
+	return default_registry.List()
+}
+
+// DestroyGroup removes a group from the default registry, stops its
+// background workers and drops its cache.
+func DestroyGroup(group_name string) bool {
+	return default_registry.Destroy(group_name)
+}
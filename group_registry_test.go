@@ -0,0 +1,99 @@
+package lru_cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func registry_test_getter() Getter {
+	return GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value"), nil
+	})
+}
+
+func TestGroupRegistryDuplicatePolicy(t *testing.T) {
+	registry := NewGroupRegistry(DuplicateError)
+	first := NewGroup("dup", 1<<10, registry_test_getter(), WithRegistry(registry))
+
+	func() {
+		defer func() {
+			recovered, _ := recover().(error)
+			if !errors.Is(recovered, ErrGroupExists) {
+				t.Fatalf("expected ErrGroupExists panic, got %v", recovered)
+			}
+		}()
+		NewGroup("dup", 1<<10, registry_test_getter(), WithRegistry(registry))
+	}()
+	if registry.Get("dup") != first {
+		t.Fatalf("expected the first group to stay registered")
+	}
+	meta_getter := MetaGetterFunc(func(request_context context.Context, key string) ([]byte, EntryMeta, error) {
+		return []byte("value"), EntryMeta{}, nil
+	})
+	if group, error_value := TryNewGroupMeta("dup", 1<<10, meta_getter, WithRegistry(registry)); group != nil || !errors.Is(error_value, ErrGroupExists) {
+		t.Fatalf("expected ErrGroupExists from TryNewGroupMeta, got %v %v", group, error_value)
+	}
+
+	unregistered := NewGroup("dup", 1<<10, registry_test_getter(), WithRegistry(nil))
+	if error_value := registry.Add(unregistered); !errors.Is(error_value, ErrGroupExists) {
+		t.Fatalf("expected ErrGroupExists from Add, got %v", error_value)
+	}
+	registry.SetDuplicatePolicy(DuplicateReplace)
+	if error_value := registry.Add(unregistered); error_value != nil || registry.Get("dup") != unregistered {
+		t.Fatalf("expected replacement, got %v", error_value)
+	}
+	if GetGroup("dup") != nil {
+		t.Fatalf("expected isolated registries to stay out of the default one")
+	}
+}
+
+func TestDestroyGroup(t *testing.T) {
+	group := NewGroup("test_group_destroy", 1<<10, registry_test_getter(), WithActiveExpiry(ExpiryOptions{Interval: time.Millisecond}))
+	group.Get("k1")
+	found := false
+	for _, name := range ListGroups() {
+		found = found || name == "test_group_destroy"
+	}
+	if !found {
+		t.Fatalf("expected ListGroups to include the group, got %v", ListGroups())
+	}
+
+	if !DestroyGroup("test_group_destroy") {
+		t.Fatalf("expected DestroyGroup to find the group")
+	}
+	if GetGroup("test_group_destroy") != nil || DestroyGroup("test_group_destroy") {
+		t.Fatalf("expected the group to be gone")
+	}
+	if group.main_cache.Len() != 0 || group.main_cache.sweeper != nil {
+		t.Fatalf("expected the cache dropped and the sweeper stopped")
+	}
+}
+
+func TestServerUsesGroupRegistry(t *testing.T) {
+	registry := NewGroupRegistry(DuplicateError)
+	NewGroup("test_group_isolated", 1<<10, registry_test_getter(), WithRegistry(registry))
+
+	server := NewServer("127.0.0.1:0", "lcache-test")
+	server.SetGroupRegistry(registry)
+	if error_value := server.Start(); error_value != nil {
+		t.Fatalf("start server: %v", error_value)
+	}
+	defer server.Stop()
+	client, error_value := NewClient(server.listener.Addr().String())
+	if error_value != nil {
+		t.Fatalf("connect: %v", error_value)
+	}
+	defer client.Close()
+
+	request_context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if value, error_value := client.Get(request_context, "test_group_isolated", "k1"); error_value != nil || string(value) != "value" {
+		t.Fatalf("expected value from the isolated group, got %q %v", value, error_value)
+	}
+	stats, error_value := client.Stats(request_context, "")
+	if error_value != nil || len(stats) != 1 {
+		t.Fatalf("expected stats for the registry's only group, got %+v %v", stats, error_value)
+	}
+}
@@ -1,6 +1,10 @@
 package lru_cache
 
 import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -57,3 +61,665 @@ func TestGroupExpiration(t *testing.T) {
 		t.Fatalf("expected getter to be called at least twice after expiration")
 	}
 }
+
+func TestGroupGetContextCancelsLoad(t *testing.T) {
+	getter := ContextGetterFunc(func(request_context context.Context, key string) ([]byte, error) {
+		<-request_context.Done()
+		return nil, request_context.Err()
+	})
+
+	group := NewGroupContext("test_group_context", 1<<20, getter)
+
+	request_context, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, error_value := group.GetContext(request_context, "k1"); !errors.Is(error_value, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", error_value)
+	}
+	if group.main_cache.Len() != 0 {
+		t.Fatalf("expected failed load not to be cached")
+	}
+}
+
+func TestGroupLoadKeepsCallerDeadline(t *testing.T) {
+	deadlines := make(chan bool, 2)
+	group := NewGroupContext("test_group_load_deadline", 1<<20, ContextGetterFunc(func(request_context context.Context, key string) ([]byte, error) {
+		_, ok := request_context.Deadline()
+		deadlines <- ok
+		return []byte("value"), nil
+	}))
+
+	request_context, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, error_value := group.GetContext(request_context, "k1"); error_value != nil {
+		t.Fatalf("unexpected error: %v", error_value)
+	}
+	if !<-deadlines {
+		t.Fatalf("expected the getter to see the caller's deadline")
+	}
+	if _, error_value := group.Get("k2"); error_value != nil || <-deadlines {
+		t.Fatalf("expected no deadline without one from the caller, got %v", error_value)
+	}
+}
+
+type test_peer_picker struct {
+	peer_getter PeerGetter
+}
+
+func (picker test_peer_picker) PickPeer(key string) (PeerGetter, bool) {
+	return picker.peer_getter, true
+}
+
+type test_peer_getter func(request_context context.Context, group_name string, key string) ([]byte, error)
+
+func (getter test_peer_getter) Get(request_context context.Context, group_name string, key string) ([]byte, error) {
+	return getter(request_context, group_name, key)
+}
+
+func TestGroupPeerFallbackPolicy(t *testing.T) {
+	var load_count int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&load_count, 1)
+		return []byte("local"), nil
+	})
+	peer_getter := test_peer_getter(func(request_context context.Context, group_name string, key string) ([]byte, error) {
+		switch key {
+		case "missing":
+			return nil, ErrNotFound
+		case "remote":
+			return nil, &RemoteError{Message: "backend down"}
+		default:
+			return nil, errors.New("connection refused")
+		}
+	})
+
+	group := NewGroup("test_group_fallback", 1<<20, getter,
+		WithPeers(test_peer_picker{peer_getter: peer_getter}),
+		WithPeerFallback(FallbackOnTransportError),
+	)
+
+	if _, error_value := group.Get("missing"); !errors.Is(error_value, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound from owner, got %v", error_value)
+	}
+	if _, error_value := group.Get("remote"); error_value == nil || error_value.Error() != "backend down" {
+		t.Fatalf("expected remote error to be returned, got %v", error_value)
+	}
+	if atomic.LoadInt32(&load_count) != 0 {
+		t.Fatalf("expected no local loads, got %d", load_count)
+	}
+	value, error_value := group.Get("unreachable")
+	if error_value != nil || value.String() != "local" {
+		t.Fatalf("expected transport error to fall back, got %q %v", value.String(), error_value)
+	}
+}
+
+func TestGroupPeerTimeout(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	})
+	peer_getter := test_peer_getter(func(request_context context.Context, group_name string, key string) ([]byte, error) {
+		<-request_context.Done()
+		return nil, request_context.Err()
+	})
+
+	group := NewGroup("test_group_peer_timeout", 1<<20, getter,
+		WithPeers(test_peer_picker{peer_getter: peer_getter}),
+		WithPeerTimeout(10*time.Millisecond),
+		WithPeerFallback(FallbackNever),
+	)
+
+	if _, error_value := group.Get("k1"); !errors.Is(error_value, context.DeadlineExceeded) {
+		t.Fatalf("expected peer timeout, got %v", error_value)
+	}
+
+	group = NewGroup("test_group_peer_timeout_zero", 1<<20, getter, WithPeerTimeout(0))
+	if group.peer_timeout != 2*time.Second {
+		t.Fatalf("expected a zero timeout to keep the default, got %v", group.peer_timeout)
+	}
+}
+
+type test_remote_peer struct {
+	delete_count int32
+}
+
+func (peer *test_remote_peer) Get(request_context context.Context, group_name string, key string) ([]byte, error) {
+	return []byte("remote"), nil
+}
+
+func (peer *test_remote_peer) Delete(request_context context.Context, group_name string, key string) error {
+	atomic.AddInt32(&peer.delete_count, 1)
+	return nil
+}
+
+type test_peer_lister struct {
+	test_peer_picker
+	peers []PeerGetter
+}
+
+func (picker test_peer_lister) ListPeers() []PeerGetter {
+	return picker.peers
+}
+
+func TestGroupRemoveReachesPeers(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	})
+	owner := &test_remote_peer{}
+	other := &test_remote_peer{}
+	picker := test_peer_lister{test_peer_picker: test_peer_picker{peer_getter: owner}, peers: []PeerGetter{owner, other}}
+
+	group := NewGroup("test_group_remove", 1<<20, getter, WithPeers(picker))
+	group.Set("k1", []byte("stale"))
+
+	if error_value := group.Remove(context.Background(), "k1"); error_value != nil {
+		t.Fatalf("unexpected error: %v", error_value)
+	}
+	if _, ok := group.main_cache.Get("k1"); ok {
+		t.Fatalf("expected k1 to be removed locally")
+	}
+	if owner.delete_count != 1 || other.delete_count != 0 {
+		t.Fatalf("expected only the owner to be invalidated, got owner=%d other=%d", owner.delete_count, other.delete_count)
+	}
+
+	WithRemoveBroadcast(true)(group)
+	if error_value := group.Remove(context.Background(), "k1"); error_value != nil {
+		t.Fatalf("unexpected error: %v", error_value)
+	}
+	if owner.delete_count != 2 || other.delete_count != 1 {
+		t.Fatalf("expected broadcast to reach each peer once, got owner=%d other=%d", owner.delete_count, other.delete_count)
+	}
+
+	func_peer := test_peer_getter(func(request_context context.Context, group_name string, key string) ([]byte, error) {
+		return nil, ErrNotFound
+	})
+	WithPeers(test_peer_lister{test_peer_picker: test_peer_picker{peer_getter: func_peer}, peers: []PeerGetter{func_peer, owner}})(group)
+	if error_value := group.Remove(context.Background(), "k1"); !errors.Is(error_value, ErrPeerUnsupported) || owner.delete_count != 3 {
+		t.Fatalf("expected uncomparable peers to be broadcast to, got %v owner=%d", error_value, owner.delete_count)
+	}
+}
+
+type test_batch_peer struct {
+	mutex   sync.Mutex
+	batches [][]string
+}
+
+func (peer *test_batch_peer) Get(request_context context.Context, group_name string, key string) ([]byte, error) {
+	return nil, errors.New("unexpected single get")
+}
+
+func (peer *test_batch_peer) MultiGet(request_context context.Context, group_name string, keys []string) ([]GetResult, error) {
+	peer.mutex.Lock()
+	peer.batches = append(peer.batches, keys)
+	peer.mutex.Unlock()
+	results := make([]GetResult, 0, len(keys))
+	for _, key := range keys {
+		if key == "remote_missing" {
+			results = append(results, GetResult{Key: key, Err: ErrNotFound})
+			continue
+		}
+		results = append(results, GetResult{Key: key, Value: ByteView{bytes: []byte("remote:" + key)}})
+	}
+	return results, nil
+}
+
+type test_key_picker map[string]PeerGetter
+
+func (picker test_key_picker) PickPeer(key string) (PeerGetter, bool) {
+	peer_getter, ok := picker[key]
+	return peer_getter, ok
+}
+
+func TestGroupGetManyBatchesPerPeer(t *testing.T) {
+	var load_count int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&load_count, 1)
+		return []byte("local:" + key), nil
+	})
+	peer_a := &test_batch_peer{}
+	peer_b := &test_batch_peer{}
+	picker := test_key_picker{"a1": peer_a, "a2": peer_a, "remote_missing": peer_a, "b1": peer_b}
+
+	group := NewGroup("test_group_get_many", 1<<20, getter, WithPeers(picker))
+	group.Set("cached", []byte("hit"))
+
+	keys := []string{"a1", "cached", "b1", "l1", "a2", "", "remote_missing", "a1"}
+	results := group.GetMany(context.Background(), keys)
+
+	expected := []string{"remote:a1", "hit", "remote:b1", "local:l1", "remote:a2", "", "", "remote:a1"}
+	for index, result := range results {
+		if result.Key != keys[index] {
+			t.Fatalf("result %d has key %q, want %q", index, result.Key, keys[index])
+		}
+		if result.Value.String() != expected[index] {
+			t.Fatalf("result %d has value %q, want %q", index, result.Value.String(), expected[index])
+		}
+	}
+	if !errors.Is(results[5].Err, ErrEmptyKey) || !errors.Is(results[6].Err, ErrNotFound) {
+		t.Fatalf("unexpected errors: %v, %v", results[5].Err, results[6].Err)
+	}
+	if len(peer_a.batches) != 1 || len(peer_a.batches[0]) != 3 || len(peer_b.batches) != 1 {
+		t.Fatalf("expected one deduplicated batch per peer, got %v and %v", peer_a.batches, peer_b.batches)
+	}
+	if atomic.LoadInt32(&load_count) != 1 {
+		t.Fatalf("expected one local load, got %d", load_count)
+	}
+}
+
+type test_func_batch_peer func(keys []string) []GetResult
+
+func (peer test_func_batch_peer) Get(request_context context.Context, group_name string, key string) ([]byte, error) {
+	return nil, errors.New("unexpected single get")
+}
+
+func (peer test_func_batch_peer) MultiGet(request_context context.Context, group_name string, keys []string) ([]GetResult, error) {
+	return peer(keys), nil
+}
+
+func TestGroupGetManyBoundsConcurrency(t *testing.T) {
+	var running, peak int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			previous := atomic.LoadInt32(&peak)
+			if current <= previous || atomic.CompareAndSwapInt32(&peak, previous, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return []byte("local:" + key), nil
+	})
+	func_peer := test_func_batch_peer(func(keys []string) []GetResult {
+		results := make([]GetResult, 0, len(keys))
+		for _, key := range keys {
+			results = append(results, GetResult{Key: key, Value: ByteView{bytes: []byte("remote:" + key)}})
+		}
+		return results
+	})
+	picker := test_key_picker{"remote_a": func_peer, "remote_b": func_peer}
+	group := NewGroup("test_group_get_many_workers", 1<<20, getter, WithPeers(picker))
+
+	keys := []string{"remote_a", "remote_b"}
+	for index := 0; index < 4*get_many_workers; index++ {
+		keys = append(keys, "k"+strconv.Itoa(index), "k"+strconv.Itoa(index))
+	}
+	results := group.GetMany(context.Background(), keys)
+	for index, result := range results {
+		want := "local:" + keys[index]
+		if index < 2 {
+			want = "remote:" + keys[index]
+		}
+		if result.Err != nil || result.Value.String() != want {
+			t.Fatalf("key %s: expected %q, got %q %v", keys[index], want, result.Value.String(), result.Err)
+		}
+	}
+	if peak := atomic.LoadInt32(&peak); peak > get_many_workers {
+		t.Fatalf("expected at most %d loads at once, got %d", get_many_workers, peak)
+	}
+}
+func TestGroupHotCacheServesPeerValues(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	})
+	var peer_count int32
+	peer_getter := test_peer_getter(func(request_context context.Context, group_name string, key string) ([]byte, error) {
+		atomic.AddInt32(&peer_count, 1)
+		return []byte("remote"), nil
+	})
+
+	group := NewGroup("test_group_hot_cache", 1<<20, getter,
+		WithPeers(test_peer_picker{peer_getter: peer_getter}),
+		WithHotCache(1<<10, 10*time.Millisecond),
+		WithHotCacheFillRate(1),
+	)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		if value, error_value := group.Get("k1"); error_value != nil || value.String() != "remote" {
+			t.Fatalf("unexpected result: %q %v", value.String(), error_value)
+		}
+	}
+	if atomic.LoadInt32(&peer_count) != 1 {
+		t.Fatalf("expected one peer fetch, got %d", peer_count)
+	}
+	if stats := group.Stats(); stats.HotHits != 2 || stats.Misses != 1 || stats.HotEntries != 1 || stats.Entries != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	group.Get("k1")
+	if atomic.LoadInt32(&peer_count) != 2 {
+		t.Fatalf("expected hot entry to expire, got %d peer fetches", peer_count)
+	}
+}
+
+func TestGroupMetaGetterControlsCaching(t *testing.T) {
+	var load_count int32
+	getter := MetaGetterFunc(func(request_context context.Context, key string) ([]byte, EntryMeta, error) {
+		atomic.AddInt32(&load_count, 1)
+		switch key {
+		case "short":
+			return []byte("v"), EntryMeta{TTL: 10 * time.Millisecond}, nil
+		case "volatile":
+			return []byte("v"), EntryMeta{NoCache: true}, nil
+		default:
+			return []byte("v"), EntryMeta{}, nil
+		}
+	})
+
+	group := NewGroupMeta("test_group_meta", 1<<20, getter, WithExpiration(time.Hour))
+
+	for _, key := range []string{"short", "volatile", "default"} {
+		group.Get(key)
+	}
+	if group.main_cache.Len() != 2 {
+		t.Fatalf("expected the no-cache value to be skipped, got %d entries", group.main_cache.Len())
+	}
+	if _, state, _ := group.main_cache.get_entry("default"); state.ttl <= 10*time.Millisecond {
+		t.Fatalf("expected default expiration for unannotated value, got %v", state.ttl)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	for _, key := range []string{"short", "volatile", "default"} {
+		group.Get(key)
+	}
+	if atomic.LoadInt32(&load_count) != 5 {
+		t.Fatalf("expected short and volatile keys to reload, got %d loads", load_count)
+	}
+}
+
+type test_clock struct {
+	mutex        sync.Mutex
+	current_time time.Time
+}
+
+func (clock *test_clock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.current_time
+}
+
+func (clock *test_clock) Advance(duration time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.current_time = clock.current_time.Add(duration)
+}
+
+func wait_for(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met before deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGroupStaleWhileRevalidate(t *testing.T) {
+	var load_count int32
+	release := make(chan struct{})
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		if atomic.AddInt32(&load_count, 1) > 1 {
+			<-release
+			return []byte("fresh"), nil
+		}
+		return []byte("old"), nil
+	})
+	clock := &test_clock{current_time: time.Unix(1000, 0)}
+
+	group := NewGroup("test_group_stale", 1<<20, getter,
+		WithExpiration(time.Minute),
+		WithStaleWhileRevalidate(30*time.Second),
+		WithClock(clock),
+	)
+	group.Get("k1")
+
+	clock.Advance(time.Minute + time.Second)
+	for attempt := 0; attempt < 3; attempt++ {
+		if value, error_value := group.Get("k1"); error_value != nil || value.String() != "old" {
+			t.Fatalf("expected stale value while refreshing, got %q %v", value.String(), error_value)
+		}
+	}
+	close(release)
+	wait_for(t, func() bool {
+		value, _ := group.Get("k1")
+		return value.String() == "fresh"
+	})
+
+	stats := group.Stats()
+	if atomic.LoadInt32(&load_count) != 2 || stats.Refreshes != 1 || stats.StaleServed < 3 || stats.Misses < 4 {
+		t.Fatalf("expected a single background refresh, got loads=%d stats=%+v", load_count, stats)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, _, ok := group.main_cache.get_entry("k1"); ok {
+		t.Fatalf("expected value past the grace window to be dropped")
+	}
+}
+
+func TestGroupRefreshAhead(t *testing.T) {
+	var load_count int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&load_count, 1)
+		return []byte("value"), nil
+	})
+	clock := &test_clock{current_time: time.Unix(1000, 0)}
+
+	group := NewGroup("test_group_refresh_ahead", 1<<20, getter,
+		WithExpiration(100*time.Second),
+		WithRefreshAhead(0.2),
+		WithClock(clock),
+	)
+	group.Get("k1")
+
+	clock.Advance(70 * time.Second)
+	group.Get("k1")
+	if atomic.LoadInt32(&load_count) != 1 {
+		t.Fatalf("expected no refresh before the last 20%% of the ttl")
+	}
+
+	clock.Advance(15 * time.Second)
+	group.Get("k1")
+	wait_for(t, func() bool { return group.Stats().Refreshes == 1 && atomic.LoadInt32(&load_count) == 2 })
+	wait_for(t, func() bool {
+		_, state, _ := group.main_cache.get_entry("k1")
+		return state.ttl == 100*time.Second
+	})
+}
+
+func TestGroupRefreshAheadSkipsHotCache(t *testing.T) {
+	var load_count int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&load_count, 1)
+		return []byte("local"), nil
+	})
+	peer_getter := test_peer_getter(func(request_context context.Context, group_name string, key string) ([]byte, error) {
+		return []byte("remote"), nil
+	})
+	clock := &test_clock{current_time: time.Unix(1000, 0)}
+
+	group := NewGroup("test_group_refresh_hot", 1<<20, getter,
+		WithPeers(test_peer_picker{peer_getter: peer_getter}),
+		WithHotCache(1<<10, 100*time.Second),
+		WithHotCacheFillRate(1),
+		WithRefreshAhead(0.2),
+		WithClock(clock),
+	)
+	group.Get("k1")
+	clock.Advance(90 * time.Second)
+	if value, error_value := group.Get("k1"); error_value != nil || value.String() != "remote" {
+		t.Fatalf("unexpected result: %q %v", value.String(), error_value)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if stats := group.Stats(); stats.Refreshes != 0 || stats.Entries != 0 || atomic.LoadInt32(&load_count) != 0 {
+		t.Fatalf("expected no local refresh of a peer-owned key, got loads=%d stats=%+v", load_count, stats)
+	}
+}
+
+func TestGroupNegativeCache(t *testing.T) {
+	var load_count int32
+	backend_error := errors.New("backend overloaded")
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&load_count, 1)
+		if key == "flaky" {
+			return nil, backend_error
+		}
+		return nil, ErrNotFound
+	})
+	clock := &test_clock{current_time: time.Unix(1000, 0)}
+
+	group := NewGroup("test_group_negative", 1<<20, getter,
+		WithNegativeCache(time.Minute),
+		WithErrorCache(time.Second, func(error_value error) bool { return errors.Is(error_value, backend_error) }),
+		WithClock(clock),
+	)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		if _, error_value := group.Get("missing"); !errors.Is(error_value, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", error_value)
+		}
+		if _, error_value := group.Get("flaky"); !errors.Is(error_value, backend_error) {
+			t.Fatalf("expected backend error, got %v", error_value)
+		}
+	}
+	if atomic.LoadInt32(&load_count) != 2 {
+		t.Fatalf("expected one load per key, got %d", load_count)
+	}
+	stats := group.Stats()
+	if stats.NegativeHits != 4 || stats.Hits != 0 || stats.Bytes != int64(len("missing")+len("flaky")) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	clock.Advance(2 * time.Second)
+	group.Get("missing")
+	group.Get("flaky")
+	if atomic.LoadInt32(&load_count) != 3 {
+		t.Fatalf("expected only the error tombstone to expire, got %d loads", load_count)
+	}
+}
+
+func TestGroupSetCacheBytes(t *testing.T) {
+	var evicted atomic.Int64
+	group := NewGroup("test_group_resize", 0, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}), WithShards(4), OnEvict(func(key string, value ByteView, reason EvictReason) {
+		if reason == EvictCapacity {
+			evicted.Add(1)
+		}
+	}))
+	for index := 0; index < 100; index++ {
+		if _, error_value := group.Get("k" + strconv.Itoa(index)); error_value != nil {
+			t.Fatalf("unexpected error: %v", error_value)
+		}
+	}
+	if error_value := group.SetCacheBytes(80); error_value != nil {
+		t.Fatalf("resize: %v", error_value)
+	}
+	stats := group.Stats()
+	if stats.Bytes > 80 {
+		t.Fatalf("expected bytes within 80 after shrinking, got %d", stats.Bytes)
+	}
+	if evicted.Load() != int64(100-stats.Entries) || stats.Evictions != uint64(evicted.Load()) {
+		t.Fatalf("expected %d capacity evictions, got %d (stats %d)", 100-stats.Entries, evicted.Load(), stats.Evictions)
+	}
+
+	if error_value := group.SetCacheBytes(0); error_value != nil {
+		t.Fatalf("resize: %v", error_value)
+	}
+	for index := 100; index < 200; index++ {
+		group.Get("k" + strconv.Itoa(index))
+	}
+	if entries := group.Stats().Entries; entries != stats.Entries+100 {
+		t.Fatalf("expected unlimited growth to keep all new entries, got %d", entries)
+	}
+}
+
+func TestGroupWaiterLeavesStuckLoad(t *testing.T) {
+	release := make(chan struct{})
+	var load_count int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		if atomic.AddInt32(&load_count, 1) == 1 {
+			<-release
+			return []byte("old"), nil
+		}
+		return []byte("new"), nil
+	})
+	group := NewGroup("test_group_stuck_load", 1<<20, getter)
+
+	request_context, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, error_value := group.GetContext(request_context, "k1"); !errors.Is(error_value, context.DeadlineExceeded) {
+		t.Fatalf("expected the waiter to give up on the stuck load, got %v", error_value)
+	}
+	if error_value := group.Remove(context.Background(), "k1"); error_value != nil {
+		t.Fatalf("remove: %v", error_value)
+	}
+	if value, error_value := group.Get("k1"); error_value != nil || value.String() != "new" {
+		t.Fatalf("expected a fresh load after Remove, got %q %v", value.String(), error_value)
+	}
+
+	close(release)
+	time.Sleep(20 * time.Millisecond)
+	if value, ok := group.main_cache.Get("k1"); !ok || value.String() != "new" {
+		t.Fatalf("expected the superseded load not to overwrite the cache, got %q %v", value.String(), ok)
+	}
+}
+
+func TestGroupRemoveStopsInFlightHotFill(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	peer_getter := test_peer_getter(func(request_context context.Context, group_name string, key string) ([]byte, error) {
+		close(started)
+		<-release
+		return []byte("old"), nil
+	})
+	group := NewGroup("test_group_remove_hot_fill", 1<<20, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	}), WithPeers(test_peer_picker{peer_getter: peer_getter}), WithHotCache(1<<10, time.Hour), WithHotCacheFillRate(1))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		group.Get("k1")
+	}()
+	<-started
+	if error_value := group.Remove(context.Background(), "k1"); error_value != nil && !errors.Is(error_value, ErrPeerUnsupported) {
+		t.Fatalf("remove: %v", error_value)
+	}
+	close(release)
+	<-done
+	if value, ok := group.hot_cache.Get("k1"); ok {
+		t.Fatalf("expected the superseded peer fetch not to fill the hot cache, got %q", value.String())
+	}
+}
+
+func TestGroupLoaderPanicDoesNotWedgeKey(t *testing.T) {
+	var load_count int32
+	group := NewGroup("test_group_loader_panic", 1<<20, GetterFunc(func(key string) ([]byte, error) {
+		if atomic.AddInt32(&load_count, 1) == 1 {
+			panic("loader failed")
+		}
+		return []byte("value"), nil
+	}))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected the loader panic to reach the caller")
+			}
+		}()
+		group.Get("k1")
+	}()
+	done := make(chan error, 1)
+	go func() {
+		_, error_value := group.Get("k1")
+		done <- error_value
+	}()
+	select {
+	case error_value := <-done:
+		if error_value != nil {
+			t.Fatalf("unexpected error: %v", error_value)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the key to load again after a panic")
+	}
+}
@@ -6,21 +6,112 @@ import (
 	"google.golang.org/grpc"
 )
 
-// GetRequest is the cache fetch request.
+// GetRequest is the cache fetch request. Local asks the receiver to load a
+// miss itself rather than forward it to the key's owner; senders set it when
+// bounded loads already moved the key off a busy owner.
 type GetRequest struct {
 	Group string `json:"group"`
 	Key   string `json:"key"`
+	Local bool   `json:"local,omitempty"`
 }
 
-// GetResponse is the cache fetch response.
+// GetResponse is the cache fetch response. TTLMillis is the time the value
+// stays fresh on the owner (0 means no expiration). Tombstone marks an Err
+// that the owner has cached for TTLMillis.
 type GetResponse struct {
-	Value []byte `json:"value,omitempty"`
-	Err   string `json:"err,omitempty"`
+	Value     []byte `json:"value,omitempty"`
+	TTLMillis int64  `json:"ttl_ms,omitempty"`
+	NoCache   bool   `json:"no_cache,omitempty"`
+	Tombstone bool   `json:"tombstone,omitempty"`
+	Err       string `json:"err,omitempty"`
+}
+
+// DeleteRequest is the cache invalidation request.
+type DeleteRequest struct {
+	Group string `json:"group"`
+	Key   string `json:"key"`
+}
+
+// DeleteResponse is the cache invalidation response.
+type DeleteResponse struct {
+	Err string `json:"err,omitempty"`
+}
+
+// SetRequest stores a value on a node. A zero TTL uses the group default.
+type SetRequest struct {
+	Group     string `json:"group"`
+	Key       string `json:"key"`
+	Value     []byte `json:"value,omitempty"`
+	TTLMillis int64  `json:"ttl_ms,omitempty"`
+}
+
+// SetResponse is the cache store response.
+type SetResponse struct {
+	Err string `json:"err,omitempty"`
+}
+
+// StatsRequest asks for cache statistics. An empty group reports every group.
+type StatsRequest struct {
+	Group string `json:"group,omitempty"`
+}
+
+// GroupStats holds the statistics of one group.
+type GroupStats struct {
+	Group      string `json:"group"`
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Bytes      int64  `json:"bytes"`
+	Entries    int64  `json:"entries"`
+	HotHits    uint64 `json:"hot_hits,omitempty"`
+	HotMisses  uint64 `json:"hot_misses,omitempty"`
+	HotBytes   int64  `json:"hot_bytes,omitempty"`
+	HotEntries int64  `json:"hot_entries,omitempty"`
+
+	Evictions     uint64 `json:"evictions,omitempty"`
+	Expirations   uint64 `json:"expirations,omitempty"`
+	NegativeHits  uint64 `json:"negative_hits,omitempty"`
+	StaleServed   uint64 `json:"stale_served,omitempty"`
+	Refreshes     uint64 `json:"refreshes,omitempty"`
+	RefreshErrors uint64 `json:"refresh_errors,omitempty"`
+}
+
+// StatsResponse is the cache statistics response.
+type StatsResponse struct {
+	Groups []GroupStats `json:"groups,omitempty"`
+	Err    string       `json:"err,omitempty"`
+}
+
+// MultiGetRequest fetches several keys of one group in a single call. Local
+// has the same meaning as in GetRequest.
+type MultiGetRequest struct {
+	Group string   `json:"group"`
+	Keys  []string `json:"keys"`
+	Local bool     `json:"local,omitempty"`
+}
+
+// MultiGetEntry is the outcome of one key in a MultiGetResponse.
+type MultiGetEntry struct {
+	Key       string `json:"key"`
+	Value     []byte `json:"value,omitempty"`
+	TTLMillis int64  `json:"ttl_ms,omitempty"`
+	NoCache   bool   `json:"no_cache,omitempty"`
+	Tombstone bool   `json:"tombstone,omitempty"`
+	Err       string `json:"err,omitempty"`
+}
+
+// MultiGetResponse is the batched cache fetch response.
+type MultiGetResponse struct {
+	Entries []MultiGetEntry `json:"entries,omitempty"`
+	Err     string          `json:"err,omitempty"`
 }
 
 // LCacheClient is the client API for LCache service.
 type LCacheClient interface {
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	MultiGet(ctx context.Context, in *MultiGetRequest, opts ...grpc.CallOption) (*MultiGetResponse, error)
 }
 
 type lCacheClient struct {
@@ -41,9 +132,49 @@ func (c *lCacheClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.Cal
 	return out, nil
 }
 
+func (c *lCacheClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/lcache.LCache/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lCacheClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error) {
+	out := new(SetResponse)
+	err := c.cc.Invoke(ctx, "/lcache.LCache/Set", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lCacheClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, "/lcache.LCache/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lCacheClient) MultiGet(ctx context.Context, in *MultiGetRequest, opts ...grpc.CallOption) (*MultiGetResponse, error) {
+	out := new(MultiGetResponse)
+	err := c.cc.Invoke(ctx, "/lcache.LCache/MultiGet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LCacheServer is the server API for LCache service.
 type LCacheServer interface {
 	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	MultiGet(context.Context, *MultiGetRequest) (*MultiGetResponse, error)
 }
 
 // RegisterLCacheServer registers the server.
@@ -60,6 +191,22 @@ var LCache_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Get",
 			Handler:    _LCache_Get_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _LCache_Delete_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _LCache_Set_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _LCache_Stats_Handler,
+		},
+		{
+			MethodName: "MultiGet",
+			Handler:    _LCache_MultiGet_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "lcache.proto",
@@ -82,3 +229,75 @@ func _LCache_Get_Handler(srv interface{}, ctx context.Context, dec func(interfac
 	}
 	return interceptor(ctx, in, info, handler)
 }
+
+func _LCache_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LCacheServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lcache.LCache/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LCacheServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LCache_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LCacheServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lcache.LCache/Set",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LCacheServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LCache_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LCacheServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lcache.LCache/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LCacheServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LCache_MultiGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MultiGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LCacheServer).MultiGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lcache.LCache/MultiGet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LCacheServer).MultiGet(ctx, req.(*MultiGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
@@ -7,7 +7,40 @@ type PeerPicker interface {
 	PickPeer(key string) (PeerGetter, bool)
 }
 
+// PeerSetPicker is implemented by pickers that can name backup owners for
+// replication, failover reads and hedged requests.
+type PeerSetPicker interface {
+	// PickPeers returns up to n remote owners of key, the primary first. When
+	// this node is one of the n owners it is left out, so fewer come back,
+	// and self_rank is its position among them (0 when it is the primary);
+	// otherwise self_rank is -1.
+	PickPeers(key string, n int) (peers []PeerGetter, self_rank int)
+}
+
 // PeerGetter fetches data from a peer.
 type PeerGetter interface {
 	Get(request_context context.Context, group_name string, key string) ([]byte, error)
 }
+
+// PeerMetaGetter fetches data from a peer together with the owner's remaining
+// TTL and caching hints.
+type PeerMetaGetter interface {
+	GetWithMeta(request_context context.Context, group_name string, key string) ([]byte, EntryMeta, error)
+}
+
+// PeerRemover deletes a key held by a peer.
+type PeerRemover interface {
+	Delete(request_context context.Context, group_name string, key string) error
+}
+
+// PeerLister enumerates all remote peers, key owners included, so
+// invalidations can be broadcast.
+type PeerLister interface {
+	ListPeers() []PeerGetter
+}
+
+// PeerMultiGetter fetches several keys from a peer in one round trip. The
+// returned error covers the call itself; per-key errors are in the results.
+type PeerMultiGetter interface {
+	MultiGet(request_context context.Context, group_name string, keys []string) ([]GetResult, error)
+}
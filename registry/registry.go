@@ -2,6 +2,7 @@ package registry
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -22,6 +23,14 @@ type Registration struct {
 	stop_channel chan struct{}
 }
 
+// Instance describes one registered server. Weight sets its share of the
+// keyspace relative to the other instances.
+type Instance struct {
+	Address  string            `json:"address"`
+	Weight   int               `json:"weight,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
 // New creates a Registry.
 func New(client *clientv3.Client) *Registry {
 	return &Registry{client: client}
@@ -29,12 +38,25 @@ func New(client *clientv3.Client) *Registry {
 
 // Register registers a service address with TTL.
 func (registry *Registry) Register(request_context context.Context, service_name, address string, ttl time.Duration) (*Registration, error) {
+	return registry.register(request_context, service_name, address, address, ttl)
+}
+
+// RegisterWithMeta registers an instance with its weight and metadata.
+func (registry *Registry) RegisterWithMeta(request_context context.Context, service_name string, instance Instance, ttl time.Duration) (*Registration, error) {
+	value, error_value := json.Marshal(instance)
+	if error_value != nil {
+		return nil, error_value
+	}
+	return registry.register(request_context, service_name, instance.Address, string(value), ttl)
+}
+
+func (registry *Registry) register(request_context context.Context, service_name, address, value string, ttl time.Duration) (*Registration, error) {
 	lease, error_value := registry.client.Grant(request_context, int64(ttl.Seconds()))
 	if error_value != nil {
 		return nil, error_value
 	}
 	key_name := fmt.Sprintf("%s/%s", service_name, address)
-	if _, error_value := registry.client.Put(request_context, key_name, address, clientv3.WithLease(lease.ID)); error_value != nil {
+	if _, error_value := registry.client.Put(request_context, key_name, value, clientv3.WithLease(lease.ID)); error_value != nil {
 		return nil, error_value
 	}
 	keep_alive_channel, error_value := registry.client.KeepAlive(request_context, lease.ID)
@@ -68,35 +90,81 @@ func (registration *Registration) Close(request_context context.Context) error {
 
 // List returns addresses for a service.
 func (registry *Registry) List(request_context context.Context, service_name string) ([]string, error) {
+	instances, error_value := registry.ListInstances(request_context, service_name)
+	if error_value != nil {
+		return nil, error_value
+	}
+	return instance_addresses(instances), nil
+}
+
+// ListInstances returns the instances of a service. Instances registered
+// without metadata get weight 1.
+func (registry *Registry) ListInstances(request_context context.Context, service_name string) ([]Instance, error) {
 	prefix := service_name + "/"
 	response, error_value := registry.client.Get(request_context, prefix, clientv3.WithPrefix())
 	if error_value != nil {
 		return nil, error_value
 	}
-	addresses := make([]string, 0, len(response.Kvs))
+	instances := make([]Instance, 0, len(response.Kvs))
 	for _, key_value := range response.Kvs {
 		address := strings.TrimPrefix(string(key_value.Key), prefix)
 		if address == "" {
 			continue
 		}
-		addresses = append(addresses, address)
+		instances = append(instances, parse_instance(address, key_value.Value))
+	}
+	return instances, nil
+}
+
+func parse_instance(address string, value []byte) Instance {
+	var instance Instance
+	if json.Unmarshal(value, &instance) != nil {
+		instance = Instance{}
+	}
+	instance.Address = address
+	if instance.Weight < 1 {
+		instance.Weight = 1
+	}
+	return instance
+}
+
+func instance_addresses(instances []Instance) []string {
+	addresses := make([]string, len(instances))
+	for index, instance := range instances {
+		addresses[index] = instance.Address
 	}
-	return addresses, nil
+	return addresses
 }
 
 // Watch watches service changes and emits full address lists.
 func (registry *Registry) Watch(request_context context.Context, service_name string) <-chan []string {
 	change_channel := make(chan []string, 1)
+	go func() {
+		defer close(change_channel)
+		for instances := range registry.WatchInstances(request_context, service_name) {
+			select {
+			case change_channel <- instance_addresses(instances):
+			case <-request_context.Done():
+				return
+			}
+		}
+	}()
+	return change_channel
+}
+
+// WatchInstances watches service changes and emits full instance lists.
+func (registry *Registry) WatchInstances(request_context context.Context, service_name string) <-chan []Instance {
+	change_channel := make(chan []Instance, 1)
 	prefix := service_name + "/"
 	go func() {
 		defer close(change_channel)
 		send_snapshot := func() {
-			addresses, error_value := registry.List(request_context, service_name)
+			instances, error_value := registry.ListInstances(request_context, service_name)
 			if error_value != nil {
 				return
 			}
 			select {
-			case change_channel <- addresses:
+			case change_channel <- instances:
 			default:
 			}
 		}
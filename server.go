@@ -14,12 +14,14 @@ import (
 
 // Server hosts the cache service.
 type Server struct {
-	address      string
-	service_name string
-	grpc_server  *grpc.Server
-	listener     net.Listener
-	etcd_client  *clientv3.Client
-	registration *registry.Registration
+	address        string
+	service_name   string
+	grpc_server    *grpc.Server
+	listener       net.Listener
+	etcd_client    *clientv3.Client
+	registration   *registry.Registration
+	group_registry *GroupRegistry
+	weight         int
 }
 
 // NewServer creates a new server.
@@ -27,6 +29,29 @@ func NewServer(address, service_name string) *Server {
 	return &Server{address: address, service_name: service_name}
 }
 
+// SetWeight sets the weight published by RegisterEtcd, so pickers using
+// SetWeightedPeers give this server a proportional share of the keyspace.
+func (server *Server) SetWeight(weight int) {
+	server.weight = weight
+}
+
+// SetGroupRegistry makes the server serve the groups of group_registry
+// instead of the default registry. Call it before Start.
+func (server *Server) SetGroupRegistry(group_registry *GroupRegistry) {
+	server.group_registry = group_registry
+}
+
+func (server *Server) groups() *GroupRegistry {
+	if server.group_registry != nil {
+		return server.group_registry
+	}
+	return default_registry
+}
+
+func (server *Server) group(group_name string) *Group {
+	return server.groups().Get(group_name)
+}
+
 // Start starts the gRPC server.
 func (server *Server) Start() error {
 	listener, error_value := net.Listen("tcp", server.address)
@@ -65,7 +90,8 @@ func (server *Server) RegisterEtcd(request_context context.Context, endpoints []
 	}
 	server.etcd_client = client
 	registry_client := registry.New(client)
-	registration, error_value := registry_client.Register(request_context, server.service_name, server.address, ttl)
+	instance := registry.Instance{Address: server.address, Weight: max(server.weight, 1)}
+	registration, error_value := registry_client.RegisterWithMeta(request_context, server.service_name, instance, ttl)
 	if error_value != nil {
 		return error_value
 	}
@@ -75,13 +101,98 @@ func (server *Server) RegisterEtcd(request_context context.Context, endpoints []
 
 // Get handles peer cache requests.
 func (server *Server) Get(request_context context.Context, request *pb.GetRequest) (*pb.GetResponse, error) {
-	group := GetGroup(request.Group)
+	group := server.group(request.Group)
 	if group == nil {
-		return &pb.GetResponse{Err: ErrNotFound.Error()}, nil
+		return &pb.GetResponse{Err: ErrGroupNotFound.Error()}, nil
 	}
-	view, error_value := group.Get(request.Key)
+	view, meta, error_value := group.get_with_meta(request_context, request.Key, request.Local)
 	if error_value != nil {
-		return &pb.GetResponse{Err: error_value.Error()}, nil
+		return &pb.GetResponse{Err: error_value.Error(), Tombstone: meta.TTL > 0, TTLMillis: ttl_to_millis(meta.TTL)}, nil
+	}
+	return &pb.GetResponse{Value: view.ByteSlice(), TTLMillis: ttl_to_millis(meta.TTL), NoCache: meta.NoCache}, nil
+}
+
+// MultiGet handles batched peer cache requests.
+func (server *Server) MultiGet(request_context context.Context, request *pb.MultiGetRequest) (*pb.MultiGetResponse, error) {
+	group := server.group(request.Group)
+	if group == nil {
+		return &pb.MultiGetResponse{Err: ErrGroupNotFound.Error()}, nil
+	}
+	results := group.get_many(request_context, request.Keys, request.Local)
+	response := &pb.MultiGetResponse{Entries: make([]pb.MultiGetEntry, 0, len(results))}
+	for _, result := range results {
+		entry := pb.MultiGetEntry{Key: result.Key}
+		if result.Err != nil {
+			entry.Err = result.Err.Error()
+			entry.Tombstone = result.Meta.TTL > 0
+			entry.TTLMillis = ttl_to_millis(result.Meta.TTL)
+		} else {
+			entry.Value = result.Value.ByteSlice()
+			entry.TTLMillis = ttl_to_millis(result.Meta.TTL)
+			entry.NoCache = result.Meta.NoCache
+		}
+		response.Entries = append(response.Entries, entry)
+	}
+	return response, nil
+}
+
+// Delete handles peer invalidation requests. The key is only removed locally;
+// the requesting node is responsible for fanning out.
+func (server *Server) Delete(request_context context.Context, request *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	group := server.group(request.Group)
+	if group == nil {
+		return &pb.DeleteResponse{Err: ErrGroupNotFound.Error()}, nil
+	}
+	group.remove_locally(request.Key)
+	return &pb.DeleteResponse{}, nil
+}
+
+// Set handles remote writes into a local group.
+func (server *Server) Set(request_context context.Context, request *pb.SetRequest) (*pb.SetResponse, error) {
+	group := server.group(request.Group)
+	if group == nil {
+		return &pb.SetResponse{Err: ErrGroupNotFound.Error()}, nil
+	}
+	if request.Key == "" {
+		return &pb.SetResponse{Err: ErrEmptyKey.Error()}, nil
+	}
+	group.SetWithTTL(request.Key, request.Value, time.Duration(request.TTLMillis)*time.Millisecond)
+	return &pb.SetResponse{}, nil
+}
+
+// Stats reports cache statistics for one group, or every group when none is named.
+func (server *Server) Stats(request_context context.Context, request *pb.StatsRequest) (*pb.StatsResponse, error) {
+	var groups []*Group
+	if request.Group != "" {
+		group := server.group(request.Group)
+		if group == nil {
+			return &pb.StatsResponse{Err: ErrGroupNotFound.Error()}, nil
+		}
+		groups = append(groups, group)
+	} else {
+		groups = server.groups().list()
+	}
+	response := &pb.StatsResponse{Groups: make([]pb.GroupStats, 0, len(groups))}
+	for _, group := range groups {
+		stats := group.Stats()
+		response.Groups = append(response.Groups, pb.GroupStats{
+			Group:      stats.Name,
+			Hits:       stats.Hits,
+			Misses:     stats.Misses,
+			Bytes:      stats.Bytes,
+			Entries:    int64(stats.Entries),
+			HotHits:    stats.HotHits,
+			HotMisses:  stats.HotMisses,
+			HotBytes:   stats.HotBytes,
+			HotEntries: int64(stats.HotEntries),
+
+			Evictions:     stats.Evictions,
+			Expirations:   stats.Expirations,
+			NegativeHits:  stats.NegativeHits,
+			StaleServed:   stats.StaleServed,
+			Refreshes:     stats.Refreshes,
+			RefreshErrors: stats.RefreshErrors,
+		})
 	}
-	return &pb.GetResponse{Value: view.ByteSlice()}, nil
+	return response, nil
 }
@@ -0,0 +1,219 @@
+package lru_cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"lru_cache/pb"
+)
+
+func TestServerManagementRPCs(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	})
+	group := NewGroup("test_group_server_rpc", 1<<20, getter)
+
+	server := NewServer("127.0.0.1:0", "lcache-test")
+	if error_value := server.Start(); error_value != nil {
+		t.Fatalf("start server: %v", error_value)
+	}
+	defer server.Stop()
+
+	client, error_value := NewClient(server.listener.Addr().String())
+	if error_value != nil {
+		t.Fatalf("connect: %v", error_value)
+	}
+	defer client.Close()
+
+	request_context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if error_value := client.Set(request_context, group.Name(), "k1", []byte("v1"), time.Minute); error_value != nil {
+		t.Fatalf("set: %v", error_value)
+	}
+	value, error_value := client.Get(request_context, group.Name(), "k1")
+	if error_value != nil || string(value) != "v1" {
+		t.Fatalf("expected v1 after remote set, got %q %v", value, error_value)
+	}
+
+	stats, error_value := client.Stats(request_context, group.Name())
+	if error_value != nil {
+		t.Fatalf("stats: %v", error_value)
+	}
+	if len(stats) != 1 || stats[0].Entries != 1 || stats[0].Hits != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	if error_value := client.Delete(request_context, group.Name(), "k1"); error_value != nil {
+		t.Fatalf("delete: %v", error_value)
+	}
+	if _, error_value := client.Get(request_context, group.Name(), "k1"); !errors.Is(error_value, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after remote delete, got %v", error_value)
+	}
+	if _, error_value := client.Stats(request_context, "no_such_group"); !errors.Is(error_value, ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound for unknown group, got %v", error_value)
+	}
+}
+
+func TestServerGetCarriesRemainingTTL(t *testing.T) {
+	getter := MetaGetterFunc(func(request_context context.Context, key string) ([]byte, EntryMeta, error) {
+		return []byte("v"), EntryMeta{TTL: time.Minute}, nil
+	})
+	group := NewGroupMeta("test_group_server_ttl", 1<<20, getter)
+
+	server := NewServer("127.0.0.1:0", "lcache-test")
+	if error_value := server.Start(); error_value != nil {
+		t.Fatalf("start server: %v", error_value)
+	}
+	defer server.Stop()
+
+	client, error_value := NewClient(server.listener.Addr().String())
+	if error_value != nil {
+		t.Fatalf("connect: %v", error_value)
+	}
+	defer client.Close()
+
+	request_context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for attempt := 0; attempt < 2; attempt++ {
+		_, meta, error_value := client.GetWithMeta(request_context, group.Name(), "k1")
+		if error_value != nil {
+			t.Fatalf("get: %v", error_value)
+		}
+		if meta.TTL <= 0 || meta.TTL > time.Minute {
+			t.Fatalf("expected remaining ttl within a minute, got %v", meta.TTL)
+		}
+	}
+}
+
+func TestServerGetReportsTombstone(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	})
+	group := NewGroup("test_group_server_tombstone", 1<<20, getter, WithNegativeCache(time.Minute))
+
+	server := NewServer("127.0.0.1:0", "lcache-test")
+	if error_value := server.Start(); error_value != nil {
+		t.Fatalf("start server: %v", error_value)
+	}
+	defer server.Stop()
+
+	client, error_value := NewClient(server.listener.Addr().String())
+	if error_value != nil {
+		t.Fatalf("connect: %v", error_value)
+	}
+	defer client.Close()
+
+	request_context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, meta, error_value := client.GetWithMeta(request_context, group.Name(), "missing")
+	if !errors.Is(error_value, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", error_value)
+	}
+	if meta.TTL <= 0 || meta.TTL > time.Minute {
+		t.Fatalf("expected tombstone ttl within a minute, got %v", meta.TTL)
+	}
+
+	requester := NewGroup("test_group_server_tombstone_requester", 1<<20, getter,
+		WithPeers(test_peer_picker{peer_getter: client}),
+		WithNegativeCache(time.Minute),
+		WithHotCache(1<<10, time.Minute),
+	)
+	requester.group_name = group.Name()
+	if _, error_value := requester.GetContext(request_context, "missing"); !errors.Is(error_value, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound through the peer, got %v", error_value)
+	}
+	if _, _, ok := requester.hot_cache.get_entry("missing"); !ok {
+		t.Fatalf("expected the peer tombstone to be copied into the hot cache")
+	}
+}
+
+func TestServerLocalGetDoesNotForward(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	})
+	peer_getter := test_peer_getter(func(request_context context.Context, group_name string, key string) ([]byte, error) {
+		t.Errorf("expected a local request not to be forwarded")
+		return nil, errors.New("forwarded")
+	})
+	group := NewGroup("test_group_server_local", 1<<20, getter, WithPeers(test_peer_picker{peer_getter: peer_getter}))
+	defer group.Close()
+
+	server := NewServer("127.0.0.1:0", "lcache-test")
+	response, error_value := server.Get(context.Background(), &pb.GetRequest{Group: group.Name(), Key: "k1", Local: true})
+	if error_value != nil || response.Err != "" || string(response.Value) != "local" {
+		t.Fatalf("expected the key to load locally, got %+v %v", response, error_value)
+	}
+	multi_response, error_value := server.MultiGet(context.Background(), &pb.MultiGetRequest{Group: group.Name(), Keys: []string{"k2"}, Local: true})
+	if error_value != nil || len(multi_response.Entries) != 1 || string(multi_response.Entries[0].Value) != "local" {
+		t.Fatalf("expected the batch to load locally, got %+v %v", multi_response, error_value)
+	}
+}
+
+func TestServerUnknownGroupFallsBack(t *testing.T) {
+	server := NewServer("127.0.0.1:0", "lcache-test")
+	server.SetGroupRegistry(NewGroupRegistry(DuplicateReplace))
+	if error_value := server.Start(); error_value != nil {
+		t.Fatalf("start server: %v", error_value)
+	}
+	defer server.Stop()
+
+	client, error_value := NewClient(server.listener.Addr().String())
+	if error_value != nil {
+		t.Fatalf("connect: %v", error_value)
+	}
+	defer client.Close()
+
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	})
+	group := NewGroup("test_group_server_unknown", 1<<20, getter,
+		WithRegistry(nil),
+		WithPeers(test_peer_picker{peer_getter: client}),
+	)
+	value, error_value := group.Get("k1")
+	if error_value != nil || value.String() != "local" {
+		t.Fatalf("expected a peer without the group to fall back, got %q %v", value.String(), error_value)
+	}
+
+	group = NewGroup("test_group_server_unknown_never", 1<<20, getter,
+		WithRegistry(nil),
+		WithPeers(test_peer_picker{peer_getter: client}),
+		WithPeerFallback(FallbackNever),
+	)
+	if _, error_value := group.Get("k1"); !errors.Is(error_value, ErrGroupNotFound) || errors.Is(error_value, ErrNotFound) {
+		t.Fatalf("expected ErrGroupNotFound, got %v", error_value)
+	}
+}
+
+func TestClientSetKeepsSubMillisecondTTL(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	})
+	group := NewGroup("test_group_server_short_ttl", 1<<20, getter)
+
+	server := NewServer("127.0.0.1:0", "lcache-test")
+	if error_value := server.Start(); error_value != nil {
+		t.Fatalf("start server: %v", error_value)
+	}
+	defer server.Stop()
+
+	client, error_value := NewClient(server.listener.Addr().String())
+	if error_value != nil {
+		t.Fatalf("connect: %v", error_value)
+	}
+	defer client.Close()
+
+	request_context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if error_value := client.Set(request_context, group.Name(), "k1", []byte("v1"), 500*time.Microsecond); error_value != nil {
+		t.Fatalf("set: %v", error_value)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, error_value := group.Get("k1"); !errors.Is(error_value, ErrNotFound) {
+		t.Fatalf("expected a sub-millisecond TTL to expire, got %v", error_value)
+	}
+}
@@ -1,12 +1,61 @@
 package singleflight
 
-import "sync"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// errGoexit is recorded when fn calls runtime.Goexit, so waiters do not
+// mistake the missing result for a nil error.
+var errGoexit = errors.New("runtime.Goexit was called")
+
+// panic_error carries a value recovered from fn together with the stack of
+// the goroutine that panicked, and is re-panicked in every waiter.
+type panic_error struct {
+	value interface{}
+	stack []byte
+}
+
+func (panic_value *panic_error) Error() string {
+	return fmt.Sprintf("%v\n\n%s", panic_value.value, panic_value.stack)
+}
+
+func (panic_value *panic_error) Unwrap() error {
+	error_value, _ := panic_value.value.(error)
+	return error_value
+}
+
+func new_panic_error(value interface{}) error {
+	stack := debug.Stack()
+	// Drop the first line, "goroutine N [status]:", since this goroutine's
+	// number will not match the one each waiter re-panics in.
+	if line := bytes.IndexByte(stack, '\n'); line >= 0 {
+		stack = stack[line+1:]
+	}
+	return &panic_error{value: value, stack: stack}
+}
 
 type call struct {
-	wait_group      sync.WaitGroup
+	done            chan struct{}
 	value           interface{}
 	error_value     error
 	duplicate_count int
+	channels        []chan<- Result
+	waiter_count    int                // callers still waiting, guarded by the group mutex
+	cancel          context.CancelFunc // cancels a DoContext call once every waiter left
+	detached        bool               // fn runs on its own goroutine rather than a caller's
+}
+
+// Result holds the outcome of a call delivered by DoChan.
+type Result struct {
+	Value  interface{}
+	Err    error
+	Shared bool
 }
 
 // Group manages duplicate suppression.
@@ -18,26 +67,169 @@ type Group struct {
 // Do executes and suppresses duplicate calls for the same key.
 func (group *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error, bool) {
 	group.mutex.Lock()
-	if group.call_map == nil {
-		group.call_map = make(map[string]*call)
-	}
-	if existing_call, ok := group.call_map[key]; ok {
-		existing_call.duplicate_count++
+	if existing_call, ok := group.join(key); ok {
 		group.mutex.Unlock()
-		existing_call.wait_group.Wait()
+		<-existing_call.done
+		existing_call.raise()
 		return existing_call.value, existing_call.error_value, true
 	}
-	new_call := new(call)
-	new_call.wait_group.Add(1)
-	group.call_map[key] = new_call
+	new_call := group.start(key, nil)
+	group.mutex.Unlock()
+
+	group.do_call(new_call, key, fn)
+	return new_call.value, new_call.error_value, new_call.duplicate_count > 0
+}
+
+// DoChan is like Do but returns a channel that receives the result when it is
+// ready, so callers can wait with their own timeout.
+func (group *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	channel := make(chan Result, 1)
+	group.mutex.Lock()
+	if existing_call, ok := group.join(key); ok {
+		existing_call.channels = append(existing_call.channels, channel)
+		group.mutex.Unlock()
+		return channel
+	}
+	new_call := group.start(key, nil)
+	new_call.channels = append(new_call.channels, channel)
+	new_call.detached = true
+	group.mutex.Unlock()
+
+	go group.do_call(new_call, key, fn)
+	return channel
+}
+
+// DoContext is like Do, but a caller whose request_context ends stops waiting
+// and gets its context error. fn runs with a context that keeps the first
+// caller's values and deadline and is cancelled once every waiter has gone;
+// later callers then start a fresh call rather than joining the cancelled one.
+func (group *Group) DoContext(request_context context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error, bool) {
+	group.mutex.Lock()
+	current_call, shared := group.join(key)
+	if !shared {
+		call_context, cancel := context.WithCancel(context.WithoutCancel(request_context))
+		if deadline, ok := request_context.Deadline(); ok {
+			call_context, cancel = context.WithDeadline(context.WithoutCancel(request_context), deadline)
+		}
+		current_call = group.start(key, cancel)
+		current_call.detached = true
+		go group.do_call(current_call, key, func() (interface{}, error) {
+			return fn(call_context)
+		})
+	}
 	group.mutex.Unlock()
 
-	new_call.value, new_call.error_value = fn()
-	new_call.wait_group.Done()
+	select {
+	case <-current_call.done:
+		current_call.raise()
+		return current_call.value, current_call.error_value, shared || current_call.duplicate_count > 0
+	case <-request_context.Done():
+		group.mutex.Lock()
+		current_call.waiter_count--
+		if current_call.waiter_count == 0 && current_call.cancel != nil {
+			current_call.cancel()
+			if group.call_map[key] == current_call {
+				delete(group.call_map, key)
+			}
+		}
+		group.mutex.Unlock()
+		return nil, request_context.Err(), shared
+	}
+}
 
+// Forget makes the next call for key start a fresh fn instead of joining the
+// one in flight. Callers already waiting still get the old result.
+func (group *Group) Forget(key string) {
 	group.mutex.Lock()
 	delete(group.call_map, key)
 	group.mutex.Unlock()
+}
 
-	return new_call.value, new_call.error_value, new_call.duplicate_count > 0
+// join returns the call in flight for key, counting the caller as a waiter.
+// It is called with the mutex held.
+func (group *Group) join(key string) (*call, bool) {
+	existing_call, ok := group.call_map[key]
+	if ok {
+		existing_call.duplicate_count++
+		existing_call.waiter_count++
+	}
+	return existing_call, ok
+}
+
+// start registers a new call for key. It is called with the mutex held.
+func (group *Group) start(key string, cancel context.CancelFunc) *call {
+	if group.call_map == nil {
+		group.call_map = make(map[string]*call)
+	}
+	new_call := &call{done: make(chan struct{}), waiter_count: 1, cancel: cancel}
+	group.call_map[key] = new_call
+	return new_call
+}
+
+// do_call runs fn and always unregisters the call, even when fn panics or
+// calls runtime.Goexit. Callers blocked in Do or DoContext re-raise either
+// one through raise. A panic with DoChan waiters crashes the process from a
+// fresh goroutine, since nobody could recover it; a detached panic nobody
+// waits for any more is dropped.
+func (group *Group) do_call(current_call *call, key string, fn func() (interface{}, error)) {
+	normal_return := false
+	recovered := false
+
+	defer func() {
+		if !normal_return && !recovered {
+			current_call.error_value = errGoexit
+		}
+
+		group.mutex.Lock()
+		if group.call_map[key] == current_call {
+			delete(group.call_map, key)
+		}
+		if current_call.cancel != nil {
+			current_call.cancel()
+		}
+		panic_value, panicked := current_call.error_value.(*panic_error)
+		if !panicked {
+			for _, channel := range current_call.channels {
+				channel <- Result{Value: current_call.value, Err: current_call.error_value, Shared: current_call.duplicate_count > 0}
+			}
+		}
+		group.mutex.Unlock()
+		close(current_call.done)
+
+		if panicked {
+			if len(current_call.channels) > 0 {
+				go panic(panic_value)
+				select {}
+			}
+			if !current_call.detached {
+				panic(panic_value)
+			}
+		}
+	}()
+
+	func() {
+		defer func() {
+			if !normal_return {
+				// A nil recover means runtime.Goexit, which keeps unwinding.
+				if recovered_value := recover(); recovered_value != nil {
+					current_call.error_value = new_panic_error(recovered_value)
+				}
+			}
+		}()
+		current_call.value, current_call.error_value = fn()
+		normal_return = true
+	}()
+	if !normal_return {
+		recovered = true
+	}
+}
+
+// raise re-raises a panic or Goexit from fn in a waiting caller.
+func (current_call *call) raise() {
+	if panic_value, ok := current_call.error_value.(*panic_error); ok {
+		panic(panic_value)
+	}
+	if current_call.error_value == errGoexit {
+		runtime.Goexit()
+	}
 }
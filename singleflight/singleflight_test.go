@@ -0,0 +1,275 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoDeduplicates(t *testing.T) {
+	var group Group
+	var calls atomic.Int64
+	release := make(chan struct{})
+	var wait_group sync.WaitGroup
+	for index := 0; index < 10; index++ {
+		wait_group.Add(1)
+		go func() {
+			defer wait_group.Done()
+			value, error_value, _ := group.Do("key", func() (interface{}, error) {
+				calls.Add(1)
+				<-release
+				return "value", nil
+			})
+			if error_value != nil || value != "value" {
+				t.Errorf("unexpected result %v %v", value, error_value)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wait_group.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 call, got %d", calls.Load())
+	}
+}
+
+func TestDoChan(t *testing.T) {
+	var group Group
+	release := make(chan struct{})
+	first := group.DoChan("key", func() (interface{}, error) {
+		<-release
+		return "value", nil
+	})
+	second := group.DoChan("key", func() (interface{}, error) {
+		t.Errorf("expected the second call to be shared")
+		return nil, nil
+	})
+	select {
+	case <-first:
+		t.Fatalf("expected no result before fn returns")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	for _, channel := range []<-chan Result{first, second} {
+		result := <-channel
+		if result.Value != "value" || result.Err != nil || !result.Shared {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+}
+
+func TestForgetStartsFreshCall(t *testing.T) {
+	var group Group
+	release := make(chan struct{})
+	first := group.DoChan("key", func() (interface{}, error) {
+		<-release
+		return "old", nil
+	})
+	group.Forget("key")
+	value, _, _ := group.Do("key", func() (interface{}, error) {
+		return "new", nil
+	})
+	if value != "new" {
+		t.Fatalf("expected a fresh call after Forget, got %v", value)
+	}
+	close(release)
+	if result := <-first; result.Value != "old" {
+		t.Fatalf("expected the forgotten call to finish for its waiters, got %v", result.Value)
+	}
+	value, _, _ = group.Do("key", func() (interface{}, error) {
+		return "newest", nil
+	})
+	if value != "newest" {
+		t.Fatalf("expected the finished forgotten call not to linger, got %v", value)
+	}
+}
+
+func TestDoContextWaiterCanLeave(t *testing.T) {
+	var group Group
+	release := make(chan struct{})
+	started := make(chan struct{})
+	fn := func(request_context context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		return "value", request_context.Err()
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, error_value, _ := group.DoContext(context.Background(), "key", fn)
+		result <- error_value
+	}()
+	<-started
+
+	request_context, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, error_value, shared := group.DoContext(request_context, "key", fn)
+	if !errors.Is(error_value, context.DeadlineExceeded) || !shared {
+		t.Fatalf("expected the joining waiter to time out, got %v shared=%v", error_value, shared)
+	}
+	close(release)
+	if error_value := <-result; error_value != nil {
+		t.Fatalf("expected the remaining waiter to keep the call alive, got %v", error_value)
+	}
+}
+
+func TestDoContextCancelsWhenAllWaitersLeave(t *testing.T) {
+	var group Group
+	cancelled := make(chan struct{})
+	request_context, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, error_value, _ := group.DoContext(request_context, "key", func(call_context context.Context) (interface{}, error) {
+		<-call_context.Done()
+		close(cancelled)
+		return nil, call_context.Err()
+	})
+	if !errors.Is(error_value, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", error_value)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("expected fn to be cancelled once every waiter left")
+	}
+
+	value, error_value, _ := group.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+		return "fresh", nil
+	})
+	if value != "fresh" || error_value != nil {
+		t.Fatalf("expected a fresh call after cancellation, got %v %v", value, error_value)
+	}
+}
+
+func TestConcurrentMixedCalls(t *testing.T) {
+	var group Group
+	var wait_group sync.WaitGroup
+	for index := 0; index < 50; index++ {
+		wait_group.Add(3)
+		go func() {
+			defer wait_group.Done()
+			group.Do("key", func() (interface{}, error) { return 1, nil })
+		}()
+		go func() {
+			defer wait_group.Done()
+			<-group.DoChan("key", func() (interface{}, error) { return 1, nil })
+		}()
+		go func() {
+			defer wait_group.Done()
+			request_context, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			defer cancel()
+			group.DoContext(request_context, "key", func(call_context context.Context) (interface{}, error) {
+				time.Sleep(time.Millisecond)
+				return 1, nil
+			})
+			group.Forget("key")
+		}()
+	}
+	wait_group.Wait()
+}
+
+// do_with_timeout fails the test instead of hanging when a call deadlocks.
+func do_with_timeout(t *testing.T, group *Group, key string) (interface{}, error) {
+	t.Helper()
+	type outcome struct {
+		value       interface{}
+		error_value error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		value, error_value, _ := group.Do(key, func() (interface{}, error) { return "after", nil })
+		done <- outcome{value, error_value}
+	}()
+	select {
+	case result := <-done:
+		return result.value, result.error_value
+	case <-time.After(time.Second):
+		t.Fatalf("Do deadlocked on key %q", key)
+		return nil, nil
+	}
+}
+
+func TestDoPanicDoesNotDeadlock(t *testing.T) {
+	var group Group
+	func() {
+		defer func() {
+			recovered, _ := recover().(error)
+			if recovered == nil || !strings.Contains(recovered.Error(), "boom") {
+				t.Fatalf("expected the panic to reach the caller, got %v", recovered)
+			}
+		}()
+		group.Do("key", func() (interface{}, error) {
+			panic("boom")
+		})
+	}()
+	if value, error_value := do_with_timeout(t, &group, "key"); value != "after" || error_value != nil {
+		t.Fatalf("expected a fresh call after the panic, got %v %v", value, error_value)
+	}
+}
+
+func TestDoPanicReachesEveryWaiter(t *testing.T) {
+	var group Group
+	release := make(chan struct{})
+	started := make(chan struct{})
+	panics := make(chan interface{}, 2)
+	run := func(fn func() (interface{}, error)) {
+		defer func() { panics <- recover() }()
+		group.Do("key", fn)
+	}
+	go run(func() (interface{}, error) {
+		close(started)
+		<-release
+		panic(errors.New("boom"))
+	})
+	<-started
+	go run(func() (interface{}, error) { return nil, nil })
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for index := 0; index < 2; index++ {
+		recovered, _ := (<-panics).(error)
+		if recovered == nil || recovered.Error() == "boom" || !strings.Contains(recovered.Error(), "boom") {
+			t.Fatalf("expected a panic error with stack, got %v", recovered)
+		}
+	}
+}
+
+func TestDoGoexitDoesNotDeadlock(t *testing.T) {
+	var group Group
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		group.Do("key", func() (interface{}, error) {
+			runtime.Goexit()
+			return nil, nil
+		})
+		t.Errorf("expected Goexit to keep unwinding the caller")
+	}()
+	<-done
+	if value, error_value := do_with_timeout(t, &group, "key"); value != "after" || error_value != nil {
+		t.Fatalf("expected a fresh call after Goexit, got %v %v", value, error_value)
+	}
+}
+
+func TestDoContextPanicReachesWaiter(t *testing.T) {
+	var group Group
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected the detached panic to reach the waiter")
+			}
+		}()
+		group.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+			panic("boom")
+		})
+	}()
+	if value, error_value := do_with_timeout(t, &group, "key"); value != "after" || error_value != nil {
+		t.Fatalf("expected a fresh call after the panic, got %v %v", value, error_value)
+	}
+}
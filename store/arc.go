@@ -0,0 +1,246 @@
+package store
+
+import (
+	"container/list"
+	"unsafe"
+)
+
+const (
+	arc_t1 = iota
+	arc_t2
+	arc_b1
+	arc_b2
+)
+
+// arc_entry is either resident (T1/T2) or a ghost (B1/B2). Ghosts drop their
+// value and keep only the key and the size they used to occupy.
+type arc_entry struct {
+	key     string
+	value   Value
+	size    int64
+	list_id int
+}
+
+// ARC implements a non-thread-safe Adaptive Replacement Cache measured in bytes.
+// T1 holds entries seen once recently, T2 entries seen at least twice. Hits in
+// the B1/B2 ghost lists move the target size of T1 towards whichever side
+// would have avoided the miss.
+type ARC struct {
+	max_bytes  int64
+	target     int64
+	lists      [4]*list.List
+	list_bytes [4]int64
+	entry_map  map[string]*list.Element
+	on_evicted func(key string, value Value)
+	overhead   int64
+	ghost_keys int64 // key bytes held by B1 and B2
+}
+
+// NewARC creates an ARC with maxBytes (0 means no limit).
+func NewARC(max_bytes int64, on_evicted func(string, Value)) *ARC {
+	cache := &ARC{
+		max_bytes:  max_bytes,
+		entry_map:  make(map[string]*list.Element),
+		on_evicted: on_evicted,
+	}
+	for index := range cache.lists {
+		cache.lists[index] = list.New()
+	}
+	return cache
+}
+
+// SetMaxBytes scales the adaptive target with the capacity and then demotes
+// and trims entries until both the resident and ghost lists fit.
+func (cache *ARC) SetMaxBytes(max_bytes int64) {
+	if cache.max_bytes > 0 {
+		cache.target = cache.target * max_bytes / cache.max_bytes
+	}
+	cache.max_bytes = max_bytes
+	cache.target = min(cache.target, max_bytes)
+	cache.make_room(0, false)
+	cache.trim_ghosts()
+}
+
+// Target returns the current adaptive target size of T1 in bytes.
+func (cache *ARC) Target() int64 {
+	return cache.target
+}
+
+func (cache *ARC) Get(key string) (Value, bool) {
+	element, ok := cache.entry_map[key]
+	if !ok {
+		return nil, false
+	}
+	cache_entry := element.Value.(*arc_entry)
+	switch cache_entry.list_id {
+	case arc_t1:
+		cache.move_element(element, arc_t2)
+	case arc_t2:
+		cache.lists[arc_t2].MoveToFront(element)
+	default:
+		return nil, false
+	}
+	return cache_entry.value, true
+}
+
+func (cache *ARC) Peek(key string) (Value, bool) {
+	if element, ok := cache.entry_map[key]; ok {
+		if cache_entry := element.Value.(*arc_entry); cache_entry.list_id == arc_t1 || cache_entry.list_id == arc_t2 {
+			return cache_entry.value, true
+		}
+	}
+	return nil, false
+}
+
+func (cache *ARC) Add(key string, value Value) {
+	size := int64(len(key)) + int64(value.Len()) + cache.overhead
+	if cache.max_bytes != 0 && size > cache.max_bytes {
+		// The value replaces any resident one and is evicted at once, as in
+		// LRU, so only the new value is reported.
+		if element, ok := cache.entry_map[key]; ok {
+			cache.drop(element)
+		}
+		if cache.on_evicted != nil {
+			cache.on_evicted(key, value)
+		}
+		return
+	}
+
+	element, ok := cache.entry_map[key]
+	if !ok {
+		cache.make_room(size, false)
+		cache.push(&arc_entry{key: key, value: value, size: size, list_id: arc_t1})
+		cache.trim_ghosts()
+		return
+	}
+
+	cache_entry := element.Value.(*arc_entry)
+	switch cache_entry.list_id {
+	case arc_t1, arc_t2:
+		cache.list_bytes[cache_entry.list_id] += size - cache_entry.size
+		cache_entry.value = value
+		cache_entry.size = size
+		cache.move_element(element, arc_t2)
+		cache.make_room(0, false)
+	case arc_b1:
+		cache.target = min(cache.target+ghost_delta(size, cache.list_bytes[arc_b2], cache.list_bytes[arc_b1]), cache.max_bytes)
+		cache.drop(element)
+		cache.make_room(size, false)
+		cache.push(&arc_entry{key: key, value: value, size: size, list_id: arc_t2})
+	case arc_b2:
+		cache.target = max(cache.target-ghost_delta(size, cache.list_bytes[arc_b1], cache.list_bytes[arc_b2]), 0)
+		cache.drop(element)
+		cache.make_room(size, true)
+		cache.push(&arc_entry{key: key, value: value, size: size, list_id: arc_t2})
+	}
+	cache.trim_ghosts()
+}
+
+func (cache *ARC) Remove(key string) {
+	element, ok := cache.entry_map[key]
+	if !ok {
+		return
+	}
+	cache_entry := element.Value.(*arc_entry)
+	cache.drop(element)
+	if cache_entry.value != nil && cache.on_evicted != nil {
+		cache.on_evicted(cache_entry.key, cache_entry.value)
+	}
+}
+
+func (cache *ARC) Len() int {
+	return cache.lists[arc_t1].Len() + cache.lists[arc_t2].Len()
+}
+
+func (cache *ARC) Bytes() int64 {
+	return cache.list_bytes[arc_t1] + cache.list_bytes[arc_t2]
+}
+
+// make_room demotes resident entries to the ghost lists until size more bytes fit.
+func (cache *ARC) make_room(size int64, ghost_hit_in_b2 bool) {
+	if cache.max_bytes == 0 {
+		return
+	}
+	for cache.Bytes()+size > cache.max_bytes && cache.Len() > 0 {
+		cache.replace(ghost_hit_in_b2)
+	}
+}
+
+func (cache *ARC) replace(ghost_hit_in_b2 bool) {
+	t1_bytes := cache.list_bytes[arc_t1]
+	from, to := arc_t2, arc_b2
+	if cache.lists[arc_t1].Len() > 0 &&
+		(t1_bytes > cache.target || (ghost_hit_in_b2 && t1_bytes == cache.target) || cache.lists[arc_t2].Len() == 0) {
+		from, to = arc_t1, arc_b1
+	}
+	element := cache.lists[from].Back()
+	cache_entry := element.Value.(*arc_entry)
+	evicted_value := cache_entry.value
+	cache.move_element(element, to)
+	cache_entry.value = nil
+	if cache.on_evicted != nil {
+		cache.on_evicted(cache_entry.key, evicted_value)
+	}
+}
+
+// trim_ghosts keeps T1+B1 within the capacity and the whole directory within
+// twice the capacity.
+func (cache *ARC) trim_ghosts() {
+	for cache.lists[arc_b1].Len() > 0 && cache.list_bytes[arc_t1]+cache.list_bytes[arc_b1] > cache.max_bytes {
+		cache.drop(cache.lists[arc_b1].Back())
+	}
+	for cache.lists[arc_b2].Len() > 0 && cache.Bytes()+cache.list_bytes[arc_b1]+cache.list_bytes[arc_b2] > 2*cache.max_bytes {
+		cache.drop(cache.lists[arc_b2].Back())
+	}
+}
+
+func (cache *ARC) push(cache_entry *arc_entry) {
+	cache.entry_map[cache_entry.key] = cache.lists[cache_entry.list_id].PushFront(cache_entry)
+	cache.list_bytes[cache_entry.list_id] += cache_entry.size
+	if cache_entry.list_id == arc_b1 || cache_entry.list_id == arc_b2 {
+		cache.ghost_keys += int64(len(cache_entry.key))
+	}
+}
+
+func (cache *ARC) move_element(element *list.Element, list_id int) {
+	cache_entry := element.Value.(*arc_entry)
+	cache.drop(element)
+	cache_entry.list_id = list_id
+	cache.push(cache_entry)
+}
+
+func (cache *ARC) drop(element *list.Element) {
+	cache_entry := element.Value.(*arc_entry)
+	cache.lists[cache_entry.list_id].Remove(element)
+	cache.list_bytes[cache_entry.list_id] -= cache_entry.size
+	if cache_entry.list_id == arc_b1 || cache_entry.list_id == arc_b2 {
+		cache.ghost_keys -= int64(len(cache_entry.key))
+	}
+	delete(cache.entry_map, cache_entry.key)
+}
+
+// ghost_delta scales an adaptation step by the ratio of the opposite ghost
+// list to the one that was hit, never going below the entry size.
+func ghost_delta(size int64, other_bytes int64, hit_bytes int64) int64 {
+	if hit_bytes == 0 || other_bytes <= hit_bytes {
+		return size
+	}
+	return size * other_bytes / hit_bytes
+}
+
+// EntryOverhead covers resident entries; ghosts cost about the same but hold
+// no value.
+func (cache *ARC) EntryOverhead() int64 {
+	return list_entry_overhead(unsafe.Sizeof(arc_entry{}))
+}
+
+func (cache *ARC) SetEntryOverhead(bytes int64) {
+	cache.overhead = bytes
+}
+
+// GhostBytes estimates the memory of the B1 and B2 entries, which are not
+// counted in Len or Bytes.
+func (cache *ARC) GhostBytes() int64 {
+	ghosts := int64(cache.lists[arc_b1].Len() + cache.lists[arc_b2].Len())
+	return ghosts*cache.EntryOverhead() + cache.ghost_keys
+}
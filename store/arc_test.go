@@ -0,0 +1,75 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestARCGhostHitAdaptsTarget(t *testing.T) {
+	cache := NewARC(40, nil)
+	for index := 0; index < 4; index++ {
+		cache.Add("h"+strconv.Itoa(index), test_value("v1"))
+		cache.Get("h" + strconv.Itoa(index))
+	}
+	for index := 0; index < 10; index++ {
+		cache.Add("s"+strconv.Itoa(index), test_value("v1"))
+	}
+	if cache.Bytes() > 40 {
+		t.Fatalf("expected bytes within limit, got %d", cache.Bytes())
+	}
+	if _, ok := cache.Get("s3"); ok {
+		t.Fatalf("expected s3 to be evicted into the ghost list")
+	}
+	if cache.Target() != 0 {
+		t.Fatalf("expected initial target 0, got %d", cache.Target())
+	}
+
+	cache.Add("s3", test_value("v1"))
+	if cache.Target() == 0 {
+		t.Fatalf("expected a B1 ghost hit to grow the target")
+	}
+	if _, ok := cache.Get("s3"); !ok {
+		t.Fatalf("expected s3 to be resident after the ghost hit")
+	}
+}
+
+func TestARCScanResistance(t *testing.T) {
+	cache := NewARC(40, nil)
+	cache.Add("hot", test_value("v1"))
+	cache.Get("hot")
+	for index := 0; index < 100; index++ {
+		cache.Add("scan"+strconv.Itoa(index), test_value("v1"))
+	}
+	if _, ok := cache.Get("hot"); !ok {
+		t.Fatalf("expected frequently used key to survive a scan")
+	}
+}
+
+func TestARCSetMaxBytes(t *testing.T) {
+	cache := NewARC(200, nil)
+	for index := 0; index < 40; index++ {
+		cache.Add("k"+strconv.Itoa(index), test_value("v1"))
+	}
+	cache.SetMaxBytes(40)
+	if cache.Bytes() > 40 || cache.Target() > 40 {
+		t.Fatalf("expected bytes and target within 40, got %d and %d", cache.Bytes(), cache.Target())
+	}
+	if cache.list_bytes[arc_t1]+cache.list_bytes[arc_b1] > 40 {
+		t.Fatalf("expected T1+B1 within the new capacity")
+	}
+}
+
+func TestARCOversizeAddEvictsOnce(t *testing.T) {
+	var evicted []string
+	cache := NewARC(20, func(key string, value Value) {
+		evicted = append(evicted, key+"="+string(value.(test_value)))
+	})
+	cache.Add("k", test_value("small"))
+	cache.Add("k", test_value("much too large for the cache"))
+	if len(evicted) != 1 || evicted[0] != "k=much too large for the cache" {
+		t.Fatalf("expected a single event for the rejected value, got %v", evicted)
+	}
+	if _, ok := cache.Get("k"); ok || cache.Len() != 0 || cache.Bytes() != 0 {
+		t.Fatalf("expected the replaced value gone, got len=%d bytes=%d", cache.Len(), cache.Bytes())
+	}
+}
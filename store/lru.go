@@ -1,6 +1,9 @@
 package store
 
-import "container/list"
+import (
+	"container/list"
+	"unsafe"
+)
 
 type entry struct {
 	key   string
@@ -14,6 +17,7 @@ type LRU struct {
 	list       *list.List
 	entry_map  map[string]*list.Element
 	on_evicted func(key string, value Value)
+	overhead   int64
 }
 
 // NewLRU creates an LRU with maxBytes (0 means no limit).
@@ -35,6 +39,13 @@ func (cache *LRU) Get(key string) (Value, bool) {
 	return nil, false
 }
 
+func (cache *LRU) Peek(key string) (Value, bool) {
+	if element, ok := cache.entry_map[key]; ok {
+		return element.Value.(*entry).value, true
+	}
+	return nil, false
+}
+
 func (cache *LRU) Add(key string, value Value) {
 	if element, ok := cache.entry_map[key]; ok {
 		cache.list.MoveToFront(element)
@@ -46,7 +57,7 @@ func (cache *LRU) Add(key string, value Value) {
 	cache_entry := &entry{key: key, value: value}
 	element := cache.list.PushFront(cache_entry)
 	cache.entry_map[key] = element
-	cache.used_bytes += int64(len(key)) + int64(value.Len())
+	cache.used_bytes += cache.entry_bytes(key, value)
 
 	for cache.max_bytes != 0 && cache.used_bytes > cache.max_bytes {
 		cache.remove_oldest(true)
@@ -59,6 +70,13 @@ func (cache *LRU) Remove(key string) {
 	}
 }
 
+// take removes a key without calling on_evicted, for moves between caches.
+func (cache *LRU) take(key string) {
+	if element, ok := cache.entry_map[key]; ok {
+		cache.remove_element(element, false)
+	}
+}
+
 func (cache *LRU) Len() int {
 	return cache.list.Len()
 }
@@ -78,8 +96,27 @@ func (cache *LRU) remove_element(element *list.Element, call_evicted bool) {
 	cache.list.Remove(element)
 	cache_entry := element.Value.(*entry)
 	delete(cache.entry_map, cache_entry.key)
-	cache.used_bytes -= int64(len(cache_entry.key)) + int64(cache_entry.value.Len())
+	cache.used_bytes -= cache.entry_bytes(cache_entry.key, cache_entry.value)
 	if call_evicted && cache.on_evicted != nil {
 		cache.on_evicted(cache_entry.key, cache_entry.value)
 	}
 }
+
+func (cache *LRU) EntryOverhead() int64 {
+	return list_entry_overhead(unsafe.Sizeof(entry{}))
+}
+
+func (cache *LRU) SetEntryOverhead(bytes int64) {
+	cache.overhead = bytes
+}
+
+func (cache *LRU) entry_bytes(key string, value Value) int64 {
+	return int64(len(key)) + int64(value.Len()) + cache.overhead
+}
+
+func (cache *LRU) SetMaxBytes(max_bytes int64) {
+	cache.max_bytes = max_bytes
+	for cache.max_bytes != 0 && cache.used_bytes > cache.max_bytes {
+		cache.remove_oldest(true)
+	}
+}
@@ -21,7 +21,7 @@ func NewLRU2(max_bytes int64, on_evicted func(string, Value)) *LRU2 {
 	}
 	return &LRU2{
 		main_cache:    NewLRU(main_max_bytes, on_evicted),
-		history_cache: NewLRU(history_max_bytes, nil),
+		history_cache: NewLRU(history_max_bytes, on_evicted),
 	}
 }
 
@@ -30,20 +30,27 @@ func (cache *LRU2) Get(key string) (Value, bool) {
 		return value, true
 	}
 	if value, ok := cache.history_cache.Get(key); ok {
-		cache.history_cache.Remove(key)
+		cache.history_cache.take(key)
 		cache.main_cache.Add(key, value)
 		return value, true
 	}
 	return nil, false
 }
 
+func (cache *LRU2) Peek(key string) (Value, bool) {
+	if value, ok := cache.main_cache.Peek(key); ok {
+		return value, true
+	}
+	return cache.history_cache.Peek(key)
+}
+
 func (cache *LRU2) Add(key string, value Value) {
 	if _, ok := cache.main_cache.Get(key); ok {
 		cache.main_cache.Add(key, value)
 		return
 	}
 	if _, ok := cache.history_cache.Get(key); ok {
-		cache.history_cache.Remove(key)
+		cache.history_cache.take(key)
 		cache.main_cache.Add(key, value)
 		return
 	}
@@ -62,3 +69,33 @@ func (cache *LRU2) Len() int {
 func (cache *LRU2) Bytes() int64 {
 	return cache.main_cache.Bytes() + cache.history_cache.Bytes()
 }
+
+func (cache *LRU2) EntryOverhead() int64 {
+	return cache.main_cache.EntryOverhead()
+}
+
+func (cache *LRU2) SetEntryOverhead(bytes int64) {
+	cache.main_cache.SetEntryOverhead(bytes)
+	cache.history_cache.SetEntryOverhead(bytes)
+}
+
+// SetMaxBytes keeps the current history/main ratio, falling back to the
+// default quarter for history when the cache was unlimited.
+func (cache *LRU2) SetMaxBytes(max_bytes int64) {
+	var history_max_bytes int64
+	var main_max_bytes int64
+	if max_bytes > 0 {
+		old_max_bytes := cache.main_cache.max_bytes + cache.history_cache.max_bytes
+		if cache.main_cache.max_bytes > 0 && old_max_bytes > 0 {
+			history_max_bytes = max_bytes * cache.history_cache.max_bytes / old_max_bytes
+		} else {
+			history_max_bytes = max_bytes / 4
+		}
+		if history_max_bytes < 1 {
+			history_max_bytes = 1
+		}
+		main_max_bytes = max(max_bytes-history_max_bytes, 1)
+	}
+	cache.history_cache.SetMaxBytes(history_max_bytes)
+	cache.main_cache.SetMaxBytes(main_max_bytes)
+}
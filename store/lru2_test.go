@@ -1,6 +1,9 @@
 package store
 
-import "testing"
+import (
+	"strconv"
+	"testing"
+)
 
 func TestLRU2Promotion(t *testing.T) {
 	cache := NewLRU2(20, nil)
@@ -19,3 +22,38 @@ func TestLRU2Promotion(t *testing.T) {
 		t.Fatalf("expected k1 to remain in main cache after history churn")
 	}
 }
+
+func TestLRU2HistoryEvictionCallback(t *testing.T) {
+	var evicted_keys []string
+	cache := NewLRU2(20, func(key string, value Value) {
+		evicted_keys = append(evicted_keys, key)
+	})
+	cache.Add("k1", test_value("v1"))
+	cache.Get("k1")
+	cache.Add("k2", test_value("v2"))
+	cache.Add("k3", test_value("v3"))
+
+	if len(evicted_keys) != 1 || evicted_keys[0] != "k2" {
+		t.Fatalf("expected history eviction of k2 only, got %v", evicted_keys)
+	}
+}
+
+func TestLRU2SetMaxBytesKeepsSplit(t *testing.T) {
+	cache := NewLRU2(400, nil)
+	cache.SetMaxBytes(200)
+	if cache.history_cache.max_bytes != 50 || cache.main_cache.max_bytes != 150 {
+		t.Fatalf("expected 50/150 split, got %d/%d", cache.history_cache.max_bytes, cache.main_cache.max_bytes)
+	}
+	for index := 0; index < 20; index++ {
+		key := "k" + strconv.Itoa(index)
+		cache.Add(key, test_value("v"))
+		cache.Get(key)
+	}
+	cache.SetMaxBytes(40)
+	if cache.Bytes() > 40 {
+		t.Fatalf("expected bytes within 40, got %d", cache.Bytes())
+	}
+	if cache.history_cache.max_bytes != 10 || cache.main_cache.max_bytes != 30 {
+		t.Fatalf("expected 10/30 split, got %d/%d", cache.history_cache.max_bytes, cache.main_cache.max_bytes)
+	}
+}
@@ -38,3 +38,38 @@ func TestLRUEviction(t *testing.T) {
 		t.Fatalf("expected k4 to remain after adding k4")
 	}
 }
+
+func TestLRUEntryOverhead(t *testing.T) {
+	cache := NewLRU(0, nil)
+	cache.SetEntryOverhead(cache.EntryOverhead())
+	cache.Add("key", test_value("value"))
+	if expected := int64(len("key")+len("value")) + cache.EntryOverhead(); cache.Bytes() != expected {
+		t.Fatalf("expected %d bytes, got %d", expected, cache.Bytes())
+	}
+	cache.Remove("key")
+	if cache.Bytes() != 0 {
+		t.Fatalf("expected 0 bytes after remove, got %d", cache.Bytes())
+	}
+}
+
+func TestLRUSetMaxBytes(t *testing.T) {
+	var evicted []string
+	cache := NewLRU(0, func(key string, value Value) { evicted = append(evicted, key) })
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		cache.Add(key, test_value("v"))
+	}
+	cache.Get("k1")
+	cache.SetMaxBytes(6)
+	if cache.Len() != 2 || cache.Bytes() > 6 {
+		t.Fatalf("expected 2 entries within 6 bytes, got %d entries and %d bytes", cache.Len(), cache.Bytes())
+	}
+	if len(evicted) != 2 || evicted[0] != "k2" || evicted[1] != "k3" {
+		t.Fatalf("expected k2 and k3 evicted in LRU order, got %v", evicted)
+	}
+	cache.SetMaxBytes(30)
+	cache.Add("k5", test_value("v"))
+	cache.Add("k6", test_value("v"))
+	if cache.Len() != 4 {
+		t.Fatalf("expected growth to take effect immediately, got %d entries", cache.Len())
+	}
+}
@@ -0,0 +1,140 @@
+package store
+
+import "hash/fnv"
+
+const (
+	sketch_depth       = 4
+	sketch_max_counter = 15
+)
+
+// count_min_sketch estimates access frequencies with saturating 4-bit counters.
+// Counters are halved once sample_size increments have been recorded so that
+// old popularity fades out.
+type count_min_sketch struct {
+	counters     [sketch_depth][]uint8
+	mask         uint64
+	sample_size  int
+	sample_count int
+}
+
+func new_count_min_sketch(width int) *count_min_sketch {
+	width = next_power_of_two(width)
+	sketch := &count_min_sketch{
+		mask:        uint64(width - 1),
+		sample_size: width * 10,
+	}
+	for row := range sketch.counters {
+		sketch.counters[row] = make([]uint8, width)
+	}
+	return sketch
+}
+
+// increment bumps the counters for hash_value and reports whether the sketch
+// was reset as a result.
+func (sketch *count_min_sketch) increment(hash_value uint64) bool {
+	added := false
+	for row := range sketch.counters {
+		index := sketch.index(hash_value, row)
+		if sketch.counters[row][index] < sketch_max_counter {
+			sketch.counters[row][index]++
+			added = true
+		}
+	}
+	if !added {
+		return false
+	}
+	sketch.sample_count++
+	if sketch.sample_count < sketch.sample_size {
+		return false
+	}
+	sketch.reset()
+	return true
+}
+
+func (sketch *count_min_sketch) estimate(hash_value uint64) int {
+	minimum := uint8(sketch_max_counter)
+	for row := range sketch.counters {
+		if counter := sketch.counters[row][sketch.index(hash_value, row)]; counter < minimum {
+			minimum = counter
+		}
+	}
+	return int(minimum)
+}
+
+func (sketch *count_min_sketch) reset() {
+	for row := range sketch.counters {
+		for index := range sketch.counters[row] {
+			sketch.counters[row][index] >>= 1
+		}
+	}
+	sketch.sample_count /= 2
+}
+
+func (sketch *count_min_sketch) index(hash_value uint64, row int) uint64 {
+	low, high := uint32(hash_value), uint32(hash_value>>32)|1
+	return uint64(low+uint32(row)*high) & sketch.mask
+}
+
+// doorkeeper is a bloom filter that absorbs the first access of each key so
+// one-hit wonders never reach the sketch.
+type doorkeeper struct {
+	bits []uint64
+	mask uint64
+}
+
+func new_doorkeeper(bit_count int) *doorkeeper {
+	bit_count = next_power_of_two(bit_count)
+	if bit_count < 64 {
+		bit_count = 64
+	}
+	return &doorkeeper{
+		bits: make([]uint64, bit_count/64),
+		mask: uint64(bit_count - 1),
+	}
+}
+
+// add sets the bits for hash_value and reports whether they were all set already.
+func (filter *doorkeeper) add(hash_value uint64) bool {
+	present := true
+	low, high := uint32(hash_value), uint32(hash_value>>32)|1
+	for probe := uint32(0); probe < 3; probe++ {
+		bit := uint64(low+probe*high) & filter.mask
+		word, offset := bit/64, bit%64
+		if filter.bits[word]&(1<<offset) == 0 {
+			present = false
+			filter.bits[word] |= 1 << offset
+		}
+	}
+	return present
+}
+
+func (filter *doorkeeper) contains(hash_value uint64) bool {
+	low, high := uint32(hash_value), uint32(hash_value>>32)|1
+	for probe := uint32(0); probe < 3; probe++ {
+		bit := uint64(low+probe*high) & filter.mask
+		if filter.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (filter *doorkeeper) reset() {
+	for index := range filter.bits {
+		filter.bits[index] = 0
+	}
+}
+
+func hash_key(key string) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(key))
+	return hasher.Sum64()
+}
+
+func next_power_of_two(value int) int {
+	result := 1
+	for result < value {
+		result <<= 1
+	}
+	return result
+}
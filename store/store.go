@@ -1,5 +1,10 @@
 package store
 
+import (
+	"container/list"
+	"unsafe"
+)
+
 // Value is the interface values must implement to be cached.
 type Value interface {
 	Len() int
@@ -13,3 +18,48 @@ type Store interface {
 	Len() int
 	Bytes() int64
 }
+
+// Peeker is implemented by stores that can read a value without updating its
+// recency or frequency.
+type Peeker interface {
+	Peek(key string) (Value, bool)
+}
+
+// Resizer is implemented by stores whose capacity can change while in use.
+// Shrinking evicts in the store's own policy order; growing takes effect
+// immediately.
+type Resizer interface {
+	SetMaxBytes(max_bytes int64)
+}
+
+// Accountant is implemented by stores that can charge a fixed per-entry
+// overhead on top of key and value bytes, so Max_bytes tracks real memory
+// more closely when values are small.
+type Accountant interface {
+	// EntryOverhead estimates the bookkeeping bytes the store spends per entry.
+	EntryOverhead() int64
+	// SetEntryOverhead makes every entry count extra bytes towards the limit.
+	// It must be called before the first Add.
+	SetEntryOverhead(bytes int64)
+}
+
+// GhostKeeper is implemented by stores that remember keys after evicting
+// their values.
+type GhostKeeper interface {
+	// GhostBytes estimates the memory held by the remembered keys.
+	GhostBytes() int64
+}
+
+// MapEntryOverhead approximates what a Go map spends per string key and
+// word-sized value, including the slack left after growth.
+const MapEntryOverhead = 40
+
+// AllocationSize rounds size up to the 16-byte granularity of the Go
+// allocator's small size classes.
+func AllocationSize(size uintptr) int64 {
+	return int64((size + 15) &^ 15)
+}
+
+func list_entry_overhead(entry_size uintptr) int64 {
+	return AllocationSize(unsafe.Sizeof(list.Element{})) + AllocationSize(entry_size) + MapEntryOverhead
+}
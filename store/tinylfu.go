@@ -0,0 +1,284 @@
+package store
+
+import (
+	"container/list"
+	"unsafe"
+)
+
+const (
+	segment_window = iota
+	segment_probation
+	segment_protected
+)
+
+type tinylfu_entry struct {
+	key     string
+	value   Value
+	segment int
+}
+
+// TinyLFU implements a non-thread-safe W-TinyLFU cache. New entries land in a
+// small LRU admission window; entries leaving the window only enter the
+// segmented main region when the frequency sketch rates them above the victim
+// they would displace.
+type TinyLFU struct {
+	max_bytes           int64
+	window_max_bytes    int64
+	main_max_bytes      int64
+	protected_max_bytes int64
+
+	window          *list.List
+	probation       *list.List
+	protected       *list.List
+	window_bytes    int64
+	probation_bytes int64
+	protected_bytes int64
+
+	entry_map  map[string]*list.Element
+	sketch     *count_min_sketch
+	doorkeeper *doorkeeper
+	on_evicted func(key string, value Value)
+	overhead   int64
+}
+
+// NewTinyLFU creates a W-TinyLFU cache with maxBytes (0 means no limit).
+// The window uses 1% of the space and the protected segment 80% of the rest.
+func NewTinyLFU(max_bytes int64, on_evicted func(string, Value)) *TinyLFU {
+	cache := &TinyLFU{
+		max_bytes:  max_bytes,
+		window:     list.New(),
+		probation:  list.New(),
+		protected:  list.New(),
+		entry_map:  make(map[string]*list.Element),
+		on_evicted: on_evicted,
+	}
+	cache.split(max_bytes)
+	sketch_width := 1 << 16
+	if max_bytes > 0 {
+		sketch_width = int(min(max(max_bytes/64, 1024), 1<<22))
+	}
+	cache.sketch = new_count_min_sketch(sketch_width)
+	cache.doorkeeper = new_doorkeeper(sketch_width * 8)
+	return cache
+}
+
+// SetMaxBytes resizes all three segments. The sketch keeps its width, so its
+// accuracy only degrades if the cache grows by orders of magnitude.
+func (cache *TinyLFU) SetMaxBytes(max_bytes int64) {
+	cache.split(max_bytes)
+	if max_bytes == 0 {
+		return
+	}
+	for cache.protected_bytes > cache.protected_max_bytes && cache.protected.Len() > 0 {
+		cache.move_element(cache.protected.Back(), segment_probation)
+	}
+	cache.enforce_limits()
+}
+
+func (cache *TinyLFU) split(max_bytes int64) {
+	cache.max_bytes = max_bytes
+	cache.window_max_bytes, cache.main_max_bytes, cache.protected_max_bytes = 0, 0, 0
+	if max_bytes > 0 {
+		cache.window_max_bytes = max(max_bytes/100, 1)
+		cache.main_max_bytes = max_bytes - cache.window_max_bytes
+		cache.protected_max_bytes = cache.main_max_bytes * 4 / 5
+	}
+}
+
+func (cache *TinyLFU) Get(key string) (Value, bool) {
+	cache.record(key)
+	if element, ok := cache.entry_map[key]; ok {
+		cache.on_hit(element)
+		return element.Value.(*tinylfu_entry).value, true
+	}
+	return nil, false
+}
+
+func (cache *TinyLFU) Peek(key string) (Value, bool) {
+	if element, ok := cache.entry_map[key]; ok {
+		return element.Value.(*tinylfu_entry).value, true
+	}
+	return nil, false
+}
+
+func (cache *TinyLFU) Add(key string, value Value) {
+	cache.record(key)
+	if element, ok := cache.entry_map[key]; ok {
+		cache_entry := element.Value.(*tinylfu_entry)
+		cache.add_segment_bytes(cache_entry.segment, int64(value.Len())-int64(cache_entry.value.Len()))
+		cache_entry.value = value
+		cache.on_hit(element)
+		cache.enforce_limits()
+		return
+	}
+	cache_entry := &tinylfu_entry{key: key, value: value, segment: segment_window}
+	cache.entry_map[key] = cache.window.PushFront(cache_entry)
+	cache.window_bytes += cache.entry_bytes(key, value)
+	cache.enforce_limits()
+}
+
+func (cache *TinyLFU) Remove(key string) {
+	if element, ok := cache.entry_map[key]; ok {
+		cache.remove_element(element, true)
+	}
+}
+
+func (cache *TinyLFU) Len() int {
+	return len(cache.entry_map)
+}
+
+func (cache *TinyLFU) Bytes() int64 {
+	return cache.window_bytes + cache.probation_bytes + cache.protected_bytes
+}
+
+func (cache *TinyLFU) record(key string) {
+	hash_value := hash_key(key)
+	if !cache.doorkeeper.add(hash_value) {
+		return
+	}
+	if cache.sketch.increment(hash_value) {
+		cache.doorkeeper.reset()
+	}
+}
+
+func (cache *TinyLFU) frequency(key string) int {
+	hash_value := hash_key(key)
+	frequency := cache.sketch.estimate(hash_value)
+	if cache.doorkeeper.contains(hash_value) {
+		frequency++
+	}
+	return frequency
+}
+
+func (cache *TinyLFU) on_hit(element *list.Element) {
+	cache_entry := element.Value.(*tinylfu_entry)
+	switch cache_entry.segment {
+	case segment_window:
+		cache.window.MoveToFront(element)
+	case segment_protected:
+		cache.protected.MoveToFront(element)
+	case segment_probation:
+		cache.move_element(element, segment_protected)
+		for cache.max_bytes != 0 && cache.protected_bytes > cache.protected_max_bytes && cache.protected.Len() > 1 {
+			cache.move_element(cache.protected.Back(), segment_probation)
+		}
+	}
+}
+
+// enforce_limits drains the window into the main region and then trims the
+// main region until both fit their budgets.
+func (cache *TinyLFU) enforce_limits() {
+	if cache.max_bytes == 0 {
+		return
+	}
+	for cache.window_bytes > cache.window_max_bytes && cache.window.Len() > 0 {
+		cache.admit(cache.window.Back())
+	}
+	for cache.probation_bytes+cache.protected_bytes > cache.main_max_bytes {
+		victim := cache.main_victim(nil)
+		if victim == nil {
+			break
+		}
+		cache.remove_element(victim, true)
+	}
+}
+
+// admit moves a candidate evicted from the window into probation if its
+// estimated frequency beats every main-region entry that must make room for it.
+func (cache *TinyLFU) admit(candidate *list.Element) {
+	candidate_entry := candidate.Value.(*tinylfu_entry)
+	candidate_bytes := cache.entry_bytes(candidate_entry.key, candidate_entry.value)
+	if candidate_bytes > cache.main_max_bytes {
+		cache.remove_element(candidate, true)
+		return
+	}
+
+	needed_bytes := cache.probation_bytes + cache.protected_bytes + candidate_bytes - cache.main_max_bytes
+	candidate_frequency := cache.frequency(candidate_entry.key)
+	var victims []*list.Element
+	for victim := cache.main_victim(nil); needed_bytes > 0 && victim != nil; victim = cache.main_victim(victim) {
+		victim_entry := victim.Value.(*tinylfu_entry)
+		if cache.frequency(victim_entry.key) >= candidate_frequency {
+			cache.remove_element(candidate, true)
+			return
+		}
+		victims = append(victims, victim)
+		needed_bytes -= cache.entry_bytes(victim_entry.key, victim_entry.value)
+	}
+	for _, victim := range victims {
+		cache.remove_element(victim, true)
+	}
+	cache.move_element(candidate, segment_probation)
+}
+
+// main_victim returns the main-region entry evicted after previous, scanning
+// probation from its tail before falling back to protected.
+func (cache *TinyLFU) main_victim(previous *list.Element) *list.Element {
+	if previous == nil {
+		if victim := cache.probation.Back(); victim != nil {
+			return victim
+		}
+		return cache.protected.Back()
+	}
+	if victim := previous.Prev(); victim != nil {
+		return victim
+	}
+	if previous.Value.(*tinylfu_entry).segment == segment_probation {
+		return cache.protected.Back()
+	}
+	return nil
+}
+
+func (cache *TinyLFU) move_element(element *list.Element, segment int) {
+	cache_entry := element.Value.(*tinylfu_entry)
+	size := cache.entry_bytes(cache_entry.key, cache_entry.value)
+	cache.segment_list(cache_entry.segment).Remove(element)
+	cache.add_segment_bytes(cache_entry.segment, -size)
+	cache_entry.segment = segment
+	cache.entry_map[cache_entry.key] = cache.segment_list(segment).PushFront(cache_entry)
+	cache.add_segment_bytes(segment, size)
+}
+
+func (cache *TinyLFU) remove_element(element *list.Element, call_evicted bool) {
+	cache_entry := element.Value.(*tinylfu_entry)
+	cache.segment_list(cache_entry.segment).Remove(element)
+	cache.add_segment_bytes(cache_entry.segment, -cache.entry_bytes(cache_entry.key, cache_entry.value))
+	delete(cache.entry_map, cache_entry.key)
+	if call_evicted && cache.on_evicted != nil {
+		cache.on_evicted(cache_entry.key, cache_entry.value)
+	}
+}
+
+func (cache *TinyLFU) segment_list(segment int) *list.List {
+	switch segment {
+	case segment_probation:
+		return cache.probation
+	case segment_protected:
+		return cache.protected
+	default:
+		return cache.window
+	}
+}
+
+func (cache *TinyLFU) add_segment_bytes(segment int, delta int64) {
+	switch segment {
+	case segment_probation:
+		cache.probation_bytes += delta
+	case segment_protected:
+		cache.protected_bytes += delta
+	default:
+		cache.window_bytes += delta
+	}
+}
+
+func (cache *TinyLFU) EntryOverhead() int64 {
+	return list_entry_overhead(unsafe.Sizeof(tinylfu_entry{}))
+}
+
+func (cache *TinyLFU) SetEntryOverhead(bytes int64) {
+	cache.overhead = bytes
+}
+
+func (cache *TinyLFU) entry_bytes(key string, value Value) int64 {
+	return int64(len(key)) + int64(value.Len()) + cache.overhead
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestTinyLFUAdmission(t *testing.T) {
+	cache := NewTinyLFU(200, nil)
+	for round := 0; round < 5; round++ {
+		for index := 0; index < 10; index++ {
+			key := "hot" + strconv.Itoa(index)
+			if _, ok := cache.Get(key); !ok {
+				cache.Add(key, test_value("v"))
+			}
+		}
+	}
+	for index := 0; index < 1000; index++ {
+		cache.Add("scan"+strconv.Itoa(index), test_value("v"))
+	}
+	for index := 0; index < 10; index++ {
+		if _, ok := cache.Get("hot" + strconv.Itoa(index)); !ok {
+			t.Fatalf("expected hot%d to survive the scan", index)
+		}
+	}
+	if cache.Bytes() > 200 {
+		t.Fatalf("expected bytes within limit, got %d", cache.Bytes())
+	}
+}
+
+func TestTinyLFUBeatsLRU2OnZipf(t *testing.T) {
+	const key_space = 10000
+	const request_count = 200000
+	const max_bytes = 500 * 8
+
+	trace := make([]string, request_count)
+	zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.01, 1, key_space-1)
+	for index := range trace {
+		trace[index] = "key" + strconv.FormatUint(zipf.Uint64(), 10)
+	}
+
+	hit_ratio := func(cache Store) float64 {
+		hits := 0
+		for _, key := range trace {
+			if _, ok := cache.Get(key); ok {
+				hits++
+				continue
+			}
+			cache.Add(key, test_value("v"))
+		}
+		return float64(hits) / float64(len(trace))
+	}
+
+	tinylfu_ratio := hit_ratio(NewTinyLFU(max_bytes, nil))
+	lru2_ratio := hit_ratio(NewLRU2(max_bytes, nil))
+	if tinylfu_ratio <= lru2_ratio {
+		t.Fatalf("expected TinyLFU hit ratio %.4f to beat LRU2 %.4f", tinylfu_ratio, lru2_ratio)
+	}
+}
+
+func TestTinyLFUSetMaxBytes(t *testing.T) {
+	cache := NewTinyLFU(2000, nil)
+	for round := 0; round < 3; round++ {
+		for index := 0; index < 100; index++ {
+			key := "k" + strconv.Itoa(index)
+			if _, ok := cache.Get(key); !ok {
+				cache.Add(key, test_value("v"))
+			}
+		}
+	}
+	cache.SetMaxBytes(200)
+	if cache.Bytes() > 200 || cache.protected_bytes > cache.protected_max_bytes {
+		t.Fatalf("expected segments within the new budget, got %d bytes (%d protected)", cache.Bytes(), cache.protected_bytes)
+	}
+	cache.SetMaxBytes(2000)
+	for index := 0; index < 100; index++ {
+		cache.Add("n"+strconv.Itoa(index), test_value("v"))
+	}
+	if cache.Bytes() <= 200 {
+		t.Fatalf("expected the cache to grow past the old budget, got %d bytes", cache.Bytes())
+	}
+}
@@ -0,0 +1,116 @@
+package lru_cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"lru_cache/store"
+)
+
+// TypedGroup is a Group whose values are T instead of bytes. Values are
+// encoded with a Codec for storage and peer traffic, and decoded local hits
+// are kept so repeated lookups of an unchanged value skip the Decode.
+//
+// Values returned by Get are shared between callers and must not be modified.
+type TypedGroup[T any] struct {
+	group         *Group
+	codec         Codec[T]
+	decoded_mutex sync.Mutex
+	decoded       *store.LRU
+}
+
+// decoded_value remembers the version of the cached value it was decoded
+// from, so a replaced or reloaded value is never answered from the decoded
+// layer.
+type decoded_value[T any] struct {
+	version uint64
+	size    int
+	value   T
+}
+
+func (value *decoded_value[T]) Len() int {
+	return value.size
+}
+
+// NewTypedGroup creates a group whose loader returns T directly. The decoded
+// layer is bounded by cache_bytes, measured in encoded bytes.
+func NewTypedGroup[T any](group_name string, cache_bytes int64, codec Codec[T], loader func(request_context context.Context, key string) (T, error), options ...GroupOption) *TypedGroup[T] {
+	if loader == nil {
+		panic("nil loader")
+	}
+	data_getter := ContextGetterFunc(func(request_context context.Context, key string) ([]byte, error) {
+		value, error_value := loader(request_context, key)
+		if error_value != nil {
+			return nil, error_value
+		}
+		return codec.Encode(value)
+	})
+	return &TypedGroup[T]{
+		group:   NewGroupContext(group_name, cache_bytes, data_getter, options...),
+		codec:   codec,
+		decoded: store.NewLRU(cache_bytes, nil),
+	}
+}
+
+// Group returns the underlying byte-level group.
+func (typed *TypedGroup[T]) Group() *Group {
+	return typed.group
+}
+
+// Name returns the group name.
+func (typed *TypedGroup[T]) Name() string {
+	return typed.group.Name()
+}
+
+// Get returns the value for key, loading it on a miss.
+func (typed *TypedGroup[T]) Get(request_context context.Context, key string) (T, error) {
+	loaded, error_value := typed.group.get(request_context, key, false)
+	if error_value != nil {
+		var zero T
+		return zero, error_value
+	}
+	return typed.decode(key, loaded.value, loaded.version)
+}
+
+// Set encodes value and stores it with optional ttl.
+func (typed *TypedGroup[T]) Set(key string, value T, ttl time.Duration) error {
+	data, error_value := typed.codec.Encode(value)
+	if error_value != nil {
+		return error_value
+	}
+	typed.group.SetWithTTL(key, data, ttl)
+	return nil
+}
+
+// Remove deletes key from the group and the decoded layer.
+func (typed *TypedGroup[T]) Remove(request_context context.Context, key string) error {
+	typed.decoded_mutex.Lock()
+	typed.decoded.Remove(key)
+	typed.decoded_mutex.Unlock()
+	return typed.group.Remove(request_context, key)
+}
+
+// decode reuses the decoded value stored for key when it came from the same
+// version. Values that were not cached have version 0 and are always decoded.
+func (typed *TypedGroup[T]) decode(key string, view ByteView, version uint64) (T, error) {
+	if version != 0 {
+		typed.decoded_mutex.Lock()
+		if stored_value, ok := typed.decoded.Get(key); ok {
+			if decoded := stored_value.(*decoded_value[T]); decoded.version == version {
+				typed.decoded_mutex.Unlock()
+				return decoded.value, nil
+			}
+		}
+		typed.decoded_mutex.Unlock()
+	}
+
+	value, error_value := typed.codec.Decode(view.bytes)
+	if error_value != nil || version == 0 {
+		return value, error_value
+	}
+	typed.decoded_mutex.Lock()
+	typed.decoded.Add(key, &decoded_value[T]{version: version, size: view.Len(), value: value})
+	typed.decoded_mutex.Unlock()
+	return value, nil
+}
@@ -0,0 +1,87 @@
+package lru_cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type typed_user struct {
+	Name string
+	Age  int
+}
+
+type counting_codec[T any] struct {
+	Codec[T]
+	decodes atomic.Int64
+}
+
+func (codec *counting_codec[T]) Decode(data []byte) (T, error) {
+	codec.decodes.Add(1)
+	return codec.Codec.Decode(data)
+}
+
+func TestTypedGroupDecodesHitsOnce(t *testing.T) {
+	codec := &counting_codec[typed_user]{Codec: JSONCodec[typed_user]{}}
+	var loads atomic.Int64
+	group := NewTypedGroup("test_typed_json", 1<<20, codec, func(request_context context.Context, key string) (typed_user, error) {
+		loads.Add(1)
+		if key == "missing" {
+			return typed_user{}, ErrNotFound
+		}
+		return typed_user{Name: key, Age: 30}, nil
+	})
+
+	for index := 0; index < 3; index++ {
+		user, error_value := group.Get(context.Background(), "alice")
+		if error_value != nil || user.Name != "alice" || user.Age != 30 {
+			t.Fatalf("unexpected user %+v %v", user, error_value)
+		}
+	}
+	if loads.Load() != 1 || codec.decodes.Load() != 1 {
+		t.Fatalf("expected 1 load and 1 decode, got %d and %d", loads.Load(), codec.decodes.Load())
+	}
+
+	if error_value := group.Set("alice", typed_user{Name: "alice", Age: 31}, time.Minute); error_value != nil {
+		t.Fatalf("set: %v", error_value)
+	}
+	if user, _ := group.Get(context.Background(), "alice"); user.Age != 31 || codec.decodes.Load() != 2 {
+		t.Fatalf("expected the replaced value to be decoded again, got %+v after %d decodes", user, codec.decodes.Load())
+	}
+	if error_value := group.Remove(context.Background(), "alice"); error_value != nil {
+		t.Fatalf("remove: %v", error_value)
+	}
+	for index := 0; index < 2; index++ {
+		if user, _ := group.Get(context.Background(), "alice"); user.Age != 30 || loads.Load() != 2 || codec.decodes.Load() != 3 {
+			t.Fatalf("expected one reload and decode after Remove, got %+v after %d loads and %d decodes", user, loads.Load(), codec.decodes.Load())
+		}
+	}
+	if _, error_value := group.Get(context.Background(), "missing"); !errors.Is(error_value, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", error_value)
+	}
+}
+
+func TestTypedGroupCodecs(t *testing.T) {
+	gob_group := NewTypedGroup("test_typed_gob", 1<<20, GobCodec[typed_user]{}, func(request_context context.Context, key string) (typed_user, error) {
+		return typed_user{Name: key, Age: 7}, nil
+	})
+	if user, error_value := gob_group.Get(context.Background(), "bob"); error_value != nil || user != (typed_user{Name: "bob", Age: 7}) {
+		t.Fatalf("unexpected gob user %+v %v", user, error_value)
+	}
+
+	proto_group := NewTypedGroup("test_typed_proto", 1<<20, ProtoCodec[*wrapperspb.StringValue]{}, func(request_context context.Context, key string) (*wrapperspb.StringValue, error) {
+		return wrapperspb.String("value-" + key), nil
+	})
+	message, error_value := proto_group.Get(context.Background(), "k1")
+	if error_value != nil || message.GetValue() != "value-k1" {
+		t.Fatalf("unexpected proto message %v %v", message, error_value)
+	}
+	view, _ := proto_group.Group().Get("k1")
+	if decoded, _ := (ProtoCodec[*wrapperspb.StringValue]{}).Decode(view.ByteSlice()); decoded.GetValue() != "value-k1" {
+		t.Fatalf("expected the group to store the protobuf encoding")
+	}
+}
@@ -1,12 +1,42 @@
 package lru_cache
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	ErrNotFound = errors.New("lru_cache: key not found")
 	ErrEmptyKey = errors.New("lru_cache: empty key")
+
+	// ErrGroupNotFound is reported by a peer that does not serve the group,
+	// for example during a rollout. It arrives as a RemoteError and, unlike
+	// ErrNotFound, is not final: FallbackAlways still loads locally.
+	ErrGroupNotFound = errors.New("lru_cache: group not found")
+
+	ErrPeerUnsupported   = errors.New("lru_cache: peer does not support operation")
+	ErrResizeUnsupported = errors.New("lru_cache: store cannot be resized")
+
+	// ErrGroupExists is returned when a group name is already taken in a
+	// registry that does not allow replacement.
+	ErrGroupExists = errors.New("lru_cache: group already exists")
 )
 
+// RemoteError is an error reported by a peer's handler, as opposed to a
+// transport failure reaching the peer.
+type RemoteError struct {
+	Message string
+}
+
+func (remote_error *RemoteError) Error() string {
+	return remote_error.Message
+}
+
+// Is lets errors.Is match ErrGroupNotFound reported by a peer.
+func (remote_error *RemoteError) Is(target error) bool {
+	return target == ErrGroupNotFound && remote_error.Message == ErrGroupNotFound.Error()
+}
+
 func clone_bytes(bytes []byte) []byte {
 	if bytes == nil {
 		return nil
@@ -15,3 +45,12 @@ func clone_bytes(bytes []byte) []byte {
 	copy(copied_bytes, bytes)
 	return copied_bytes
 }
+
+// ttl_to_millis rounds a ttl up to whole milliseconds so that a short but
+// finite ttl never turns into 0 (no expiration) on the wire.
+func ttl_to_millis(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return int64((ttl + time.Millisecond - 1) / time.Millisecond)
+}